@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrew-d/wolf/router"
+)
+
+// Warning describes a problem with a route table found by Analyze.
+type Warning struct {
+	// The method that both routes are registered with.
+	Method string
+
+	// The pattern of the earlier route, which matches every path that the
+	// later route does.
+	Shadowing string
+
+	// The pattern of the later route, which can never be reached.
+	Shadowed string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %s is shadowed by %s", w.Method, w.Shadowed, w.Shadowing)
+}
+
+// shape is the structure of a string pattern, as a list of path segments,
+// each of which is either a literal or a parameter (":").
+type shape struct {
+	segments []string
+	wildcard bool
+}
+
+// parseShape returns the shape of a string pattern, or false if the pattern
+// contains a parameter that doesn't take up a whole segment (e.g.
+// "/:name.json"), which we don't attempt to analyze.
+func parseShape(raw string) (shape, bool) {
+	pat := router.ParseStringPattern(raw)
+	canon := pat.Canonical()
+	if !strings.HasPrefix(canon, "/") {
+		return shape{}, false
+	}
+
+	var sh shape
+	if pat.HasWildcard() {
+		sh.wildcard = true
+		canon = strings.TrimSuffix(strings.TrimSuffix(canon, "*"), "/")
+	}
+	if canon != "" {
+		sh.segments = strings.Split(canon[1:], "/")
+	}
+
+	for _, seg := range sh.segments {
+		if seg != ":" && strings.Contains(seg, ":") {
+			return shape{}, false
+		}
+	}
+	return sh, true
+}
+
+// coversSegment returns whether the segment a matches everything that the
+// segment b does.  Parameters never match an empty segment.
+func coversSegment(a, b string) bool {
+	if a == ":" {
+		return b != ""
+	}
+	return a == b
+}
+
+// covers returns whether every path matched by b is also matched by a.
+func (a shape) covers(b shape) bool {
+	k := len(a.segments)
+	if a.wildcard {
+		// The wildcard matches any tail, so b only needs to start with a's
+		// segments, and be followed by a slash.
+		if b.wildcard && len(b.segments) < k || !b.wildcard && len(b.segments) <= k {
+			return false
+		}
+	} else if b.wildcard || len(b.segments) != k {
+		return false
+	}
+
+	for i := 0; i < k; i++ {
+		if !coversSegment(a.segments[i], b.segments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Analyze looks for routes that can never be reached, since a route registered
+// before them with the same method matches every path that they do (e.g.
+// "/users/:id" followed by "/users/me").  Routers that try routes in order of
+// registration, like SimpleRouter, will never dispatch to such a route, which
+// is rarely what was intended.
+//
+// Only string patterns are analyzed; routes with other patterns (e.g. regular
+// expressions), or with parameters that don't take up a whole path segment,
+// are skipped.  Routes registered with AnyMethod are only compared to each
+// other, since routes for a specific method are always tried first.
+func Analyze(defs []RouteDef) []Warning {
+	type analyzed struct {
+		raw   string
+		shape shape
+	}
+
+	var (
+		warnings []Warning
+		seen     = make(map[string][]analyzed)
+	)
+	for _, def := range defs {
+		raw, ok := def.Pattern.(string)
+		if !ok || def.Method == MethodNotFound {
+			continue
+		}
+		sh, ok := parseShape(raw)
+		if !ok {
+			continue
+		}
+
+		method := strings.ToUpper(def.Method)
+		for _, prev := range seen[method] {
+			if prev.shape.covers(sh) {
+				warnings = append(warnings, Warning{
+					Method:    method,
+					Shadowing: prev.raw,
+					Shadowed:  raw,
+				})
+				break
+			}
+		}
+		seen[method] = append(seen[method], analyzed{raw, sh})
+	}
+	return warnings
+}
@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Get("/users/:id", noopHandler)
+	b.Get("/users/me", noopHandler)
+	b.Post("/users/me", noopHandler)
+	b.Get("/files/*", noopHandler)
+	b.Get("/files/:name", noopHandler)
+	b.Get("/files/", noopHandler)
+	b.Get("/files", noopHandler)
+	b.Get("/users/:id/posts", noopHandler)
+	b.Get("/:name.json", noopHandler)
+	b.Get("/a.json", noopHandler)
+	b.Get(regexp.MustCompile(`^/users/(\d+)$`), noopHandler)
+	b.Get("/users/:name", noopHandler)
+
+	assert.Equal(t, []Warning{
+		{Method: "GET", Shadowing: "/users/:id", Shadowed: "/users/me"},
+		{Method: "GET", Shadowing: "/files/*", Shadowed: "/files/:name"},
+		{Method: "GET", Shadowing: "/files/*", Shadowed: "/files/"},
+		{Method: "GET", Shadowing: "/users/:id", Shadowed: "/users/:name"},
+	}, Analyze(b.RouteDefs()))
+
+	w := Warning{Method: "GET", Shadowing: "/users/:id", Shadowed: "/users/me"}
+	assert.Equal(t, "GET /users/me is shadowed by /users/:id", w.String())
+
+	// Routes in the reverse order don't shadow each other.
+	b = New()
+	b.Get("/users/me", noopHandler)
+	b.Get("/users/:id", noopHandler)
+	b.Get("/files/:name", noopHandler)
+	b.Get("/files/*", noopHandler)
+	b.Get("/", noopHandler)
+	b.Get("/:name", noopHandler)
+	assert.Len(t, Analyze(b.RouteDefs()), 0)
+}
+
+func TestShapeCovers(t *testing.T) {
+	t.Parallel()
+
+	var coverTests = []struct {
+		a, b   string
+		covers bool
+	}{
+		{"/", "/", true},
+		{"/*", "/", true},
+		{"/*", "/anything/:x", true},
+		{"/:x", "/", false},
+		{"/a/*", "/a", false},
+		{"/a/*", "/a/", true},
+		{"/a/*", "/a/*", true},
+		{"/a/:x/*", "/a/*", false},
+		{"/a/:x/*", "/a/b/*", true},
+		{"/a/:x", "/a/:y", true},
+		{"/a/:x", "/a/b/c", false},
+		{"/a/b", "/a/:x", false},
+		{"/a/:x/", "/a/b/", true},
+	}
+	for _, test := range coverTests {
+		a, _ := parseShape(test.a)
+		b, _ := parseShape(test.b)
+		assert.Equal(t, test.covers, a.covers(b), test.a+" covers "+test.b)
+	}
+}
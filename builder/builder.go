@@ -16,12 +16,25 @@ type Builder interface {
 	// etc.) will be wrapped in this middleware.
 	Use(m types.MiddlewareType)
 
+	// Like Use, but also gives the middleware a name, which is included in
+	// the MiddlewareNames field of the resulting route definitions.
+	UseNamed(name string, m types.MiddlewareType)
+
+	// Like Use, but adds the middleware before all other middleware
+	// registered on this builder, rather than after them.  Middleware
+	// inherited from a parent builder still wraps it.
+	UseFront(m types.MiddlewareType)
+
 	// Create a new middleware group.  The given function is called with a new
 	// builder that is exactly the same as this builder (i.e. with no path
 	// changes), except that middleware registered on the new builder are not
 	// registered on the parent.
 	Group(fn func(r Builder))
 
+	// Like Group, but also gives the group a name, which is used as a
+	// namespace for the names of routes within it (see RouteConfig.Name).
+	GroupNamed(name string, fn func(r Builder))
+
 	// Create a subbuilder with a given prefix.  The given function is called
 	// with a new builder that registers routes with the given prefix.  Note
 	// that this does minimal parsing of the given pattern - it essentially
@@ -31,36 +44,124 @@ type Builder interface {
 	// in a subbuilder will not affect the parent.
 	Route(pattern string, fn func(r Builder))
 
+	// Like Route, but the new builder does not inherit any middleware from
+	// this builder (or its parents), giving a clean slate for the routes
+	// under the given prefix.
+	//
+	// Only string patterns can be prefixed, so registering a route with any
+	// other type of pattern under a non-empty prefix (given to Route,
+	// RouteClean or Mount) panics.
+	RouteClean(pattern string, fn func(r Builder))
+
+	// Like Route, but also gives the subbuilder a name, which is used as a
+	// namespace for the names of routes within it (see RouteConfig.Name).
+	RouteNamed(name, pattern string, fn func(r Builder))
+
 	// Mount another builder as a subbuilder.  This copies all route
 	// definitions from the given Builder to this one (including all
-	// middleware).
+	// middleware), adding the given pattern to their patterns, as with
+	// Route.  The mounted builder's NotFound handler, if any, is scoped to
+	// the pattern.  The mounted builder's routes are not wrapped in this
+	// builder's middleware; see MountInherit.
 	Mount(pattern string, sr Builder)
 
-	// Main handler method
-	Handle(method string, pattern types.PatternType, handler types.HandlerType)
+	// Like Mount, but the mounted builder inherits this builder's
+	// middleware, which wraps the mounted builder's own - so that, for
+	// example, a context value set by a middleware registered here can be
+	// read by a middleware registered on the mounted builder.
+	MountInherit(pattern string, sr Builder)
+
+	// Register an OPTIONS handler for every path under the given prefix,
+	// which responds with an Allow header listing the union of the methods
+	// of all routes whose patterns fall under the prefix.  The set of methods
+	// is computed when RouteDefs is called, and the handler is registered
+	// after all other routes, so that OPTIONS routes registered explicitly
+	// take precedence.
+	OptionsAll(prefix string)
+
+	// Register a handler for requests under this builder's prefix (e.g. the
+	// prefix given to Mount or Route) that don't match any route, so that a
+	// mounted builder can render its own 404 responses.  It is registered
+	// with the method MethodNotFound, and wrapped in this builder's
+	// middleware, like any other route.  Handlers for longer prefixes are
+	// listed first, and their patterns report the length of the prefix as
+	// their specificity (see router.SpecificPattern), so that the innermost
+	// builder's handler can take precedence.
+	NotFound(handler types.HandlerType)
+
+	// Main handler method.  The returned RouteConfig can be used to further
+	// configure the route.
+	Handle(method string, pattern types.PatternType, handler types.HandlerType) RouteConfig
+
+	// Register a handler for requests with any method, including
+	// non-standard ones (e.g. PROPFIND).  The route is registered with the
+	// method MethodAny.  Routers should prefer routes registered for the
+	// request's own method, and only try routes for any method if none of
+	// those match.
+	AnyMethod(pattern types.PatternType, handler types.HandlerType) RouteConfig
 
 	// Helper functions
-	Connect(pattern types.PatternType, handler types.HandlerType)
-	Delete(pattern types.PatternType, handler types.HandlerType)
-	Get(pattern types.PatternType, handler types.HandlerType)
-	Head(pattern types.PatternType, handler types.HandlerType)
-	Options(pattern types.PatternType, handler types.HandlerType)
-	Patch(pattern types.PatternType, handler types.HandlerType)
-	Post(pattern types.PatternType, handler types.HandlerType)
-	Put(pattern types.PatternType, handler types.HandlerType)
-	Trace(pattern types.PatternType, handler types.HandlerType)
+	Connect(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Delete(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Get(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Head(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Options(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Patch(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Post(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Put(pattern types.PatternType, handler types.HandlerType) RouteConfig
+	Trace(pattern types.PatternType, handler types.HandlerType) RouteConfig
 
 	// Returns a list of all route definitions on this builder (note: this
 	// includes all definitions from attached subbuilders, groups, etc.)
 	RouteDefs() []RouteDef
 }
 
+// MethodAny is the method of routes registered with AnyMethod.
+const MethodAny = "*"
+
+// MethodNotFound is the method of the routes registered with NotFound.  These
+// aren't real routes, and routers should only run them for requests that match
+// no other route.
+const MethodNotFound = "NOTFOUND"
+
+// RouteConfig allows configuring a single route, after it has been registered
+// on a builder.  Its methods return the same RouteConfig, so that calls can be
+// chained:
+//
+//	b.Get("/admin", handler).Meta("scope", "admin").Meta("tier", 2)
+type RouteConfig interface {
+	// Give the route a name, which is included in the Name field of the
+	// route's definition.  The name is qualified with the names of any
+	// groups containing the route, separated by dots - e.g. a route named
+	// "users" in a group named "admin" has the name "admin.users".  Unnamed
+	// groups don't contribute to the name.
+	Name(name string) RouteConfig
+
+	// Attach a piece of metadata to the route, which is included in the
+	// Meta field of the route's definition.  Routers make this available to
+	// middleware and handlers (e.g. see router.GetRouteMeta).
+	Meta(key string, val interface{}) RouteConfig
+}
+
 // This type represents a single route definition.
 type RouteDef struct {
 	Method     string
 	Pattern    types.PatternType
 	Handler    types.HandlerType
 	Middleware []types.MiddlewareType
+
+	// The names of each middleware in Middleware, in the same order, or the
+	// empty string for middleware that wasn't given a name.  If none of the
+	// route's middleware were named, this is empty.
+	MiddlewareNames []string
+
+	// The fully-qualified name of the route (see RouteConfig.Name), or the
+	// empty string if the route wasn't given a name.
+	Name string
+
+	// Arbitrary metadata attached to the route with RouteConfig.Meta, or nil
+	// if there is none.
+	Meta map[string]interface{}
 }
 
 // New creates a new builder with no existing middleware or routes.
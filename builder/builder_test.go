@@ -2,10 +2,15 @@ package builder
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/router"
+	"github.com/andrew-d/wolf/types"
 )
 
 func noopHandler(c context.Context, w http.ResponseWriter, r *http.Request) {}
@@ -103,3 +108,391 @@ func TestGroup(t *testing.T) {
 		assert.Len(t, rd[2].Middleware, 1)
 	}
 }
+
+// Test that a mounted builder's middleware is only wrapped by the parent's
+// when using MountInherit.
+func TestMountMiddleware(t *testing.T) {
+	b := New()
+	sub := New()
+
+	// Note: these aren't valid middleware, but we don't actually type-check
+	// them in the builder.
+	var mw1 interface{} = 1234
+	var mw2 interface{} = 5678
+
+	sub.Use(mw2)
+	sub.Handle("GET", "/hello", noopHandler)
+
+	b.Use(mw1)
+	b.MountInherit("", sub)
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 1) && assert.Len(t, rd[0].Middleware, 2) {
+		assert.Equal(t, rd[0].Middleware[0], mw1)
+		assert.Equal(t, rd[0].Middleware[1], mw2)
+	}
+
+	// A plain Mount doesn't inherit anything.
+	b = New()
+	b.Use(mw1)
+	b.Mount("", sub)
+
+	rd = b.RouteDefs()
+	if assert.Len(t, rd, 1) {
+		assert.Equal(t, []types.MiddlewareType{mw2}, rd[0].Middleware)
+	}
+}
+
+// Test that Route, RouteClean and Mount add their prefix to the routes
+// underneath them.
+func TestRoutePrefix(t *testing.T) {
+	b := New()
+	sub := New()
+	sub.Handle("GET", "/users", noopHandler)
+	sub2 := New()
+	sub2.Handle("GET", "/users", noopHandler)
+
+	b.Route("/api", func(b Builder) {
+		b.Handle("GET", "/status", noopHandler)
+		b.Route("/v1", func(b Builder) {
+			b.Handle("GET", "/items", noopHandler)
+		})
+	})
+	b.Mount("/admin", sub)
+	b.RouteClean("/v2", func(b Builder) {
+		b.Handle("GET", "/items", noopHandler)
+		b.RouteClean("/nested", func(b Builder) {
+			b.Handle("GET", "/stuff", noopHandler)
+		})
+		b.Mount("", sub2)
+	})
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 6) {
+		assert.Equal(t, "/api/status", rd[0].Pattern)
+		assert.Equal(t, "/api/v1/items", rd[1].Pattern)
+		assert.Equal(t, "/admin/users", rd[2].Pattern)
+		assert.Equal(t, "/v2/items", rd[3].Pattern)
+		assert.Equal(t, "/v2/nested/stuff", rd[4].Pattern)
+		assert.Equal(t, "/v2/users", rd[5].Pattern)
+	}
+}
+
+// Test that non-string patterns can't be registered under a prefix.
+func TestRoutePrefixNonString(t *testing.T) {
+	t.Parallel()
+
+	re := regexp.MustCompile(`^/users/(\d+)$`)
+
+	// Routes registered directly are rejected immediately...
+	assert.Panics(t, func() {
+		New().RouteClean("/api", func(b Builder) {
+			b.Group(func(b Builder) {
+				b.Get(re, noopHandler)
+			})
+		})
+	})
+	assert.Panics(t, func() {
+		New().Route("/api", func(b Builder) {
+			b.Get(re, noopHandler)
+		})
+	})
+
+	// ... while those in a mounted builder are rejected when flattened.
+	sub := New()
+	sub.Get(re, noopHandler)
+	b := New()
+	b.Mount("/api", sub)
+	assert.Panics(t, func() { b.RouteDefs() })
+
+	// Without a prefix, any pattern is fine.
+	b = New()
+	b.RouteClean("", func(b Builder) {
+		b.Get(re, noopHandler)
+	})
+	b.Group(func(b Builder) {
+		b.Get(re, noopHandler)
+	})
+	assert.Len(t, b.RouteDefs(), 2)
+}
+
+// Test that RouteClean adds a prefix, but doesn't inherit middleware.
+func TestRouteClean(t *testing.T) {
+	b := New()
+
+	// Note: these aren't valid middleware, but we don't actually type-check
+	// them in the builder.
+	var mw1 interface{} = 1234
+	var mw2 interface{} = 5678
+
+	b.Use(mw1)
+	b.Handle("GET", "/", noopHandler)
+	b.RouteClean("/public", func(b Builder) {
+		b.Handle("GET", "/hello", noopHandler)
+		b.Group(func(b Builder) {
+			b.Use(mw2)
+			b.Handle("GET", "/world", noopHandler)
+		})
+	})
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 3) {
+		assert.Equal(t, "/", rd[0].Pattern)
+		assert.Equal(t, []types.MiddlewareType{mw1}, rd[0].Middleware)
+
+		assert.Equal(t, "/public/hello", rd[1].Pattern)
+		assert.Len(t, rd[1].Middleware, 0)
+
+		assert.Equal(t, "/public/world", rd[2].Pattern)
+		assert.Equal(t, []types.MiddlewareType{mw2}, rd[2].Middleware)
+	}
+}
+
+// Test that middleware names are carried through to the route definitions.
+func TestUseNamed(t *testing.T) {
+	b := New()
+
+	// Note: these aren't valid middleware, but we don't actually type-check
+	// them in the builder.
+	var mw1 interface{} = 1234
+	var mw2 interface{} = 5678
+	var mw3 interface{} = 9012
+
+	b.Handle("GET", "/", noopHandler)
+	b.Group(func(b Builder) {
+		b.UseNamed("Auth", mw3)
+		b.Handle("GET", "/hello", noopHandler)
+	})
+	b.UseNamed("RequestID", mw1)
+	b.Use(mw2)
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 2) {
+		assert.Equal(t, []string{"RequestID", ""}, rd[0].MiddlewareNames)
+		assert.Equal(t, []string{"RequestID", "", "Auth"}, rd[1].MiddlewareNames)
+	}
+
+	// Routes without any named middleware have no names.
+	b = New()
+	b.Use(mw1)
+	b.Handle("GET", "/", noopHandler)
+	rd = b.RouteDefs()
+	if assert.Len(t, rd, 1) {
+		assert.Len(t, rd[0].MiddlewareNames, 0)
+	}
+}
+
+// Test that UseFront adds middleware before existing middleware.
+func TestUseFront(t *testing.T) {
+	b := New()
+
+	// Note: these aren't valid middleware, but we don't actually type-check
+	// them in the builder.
+	var mw1 interface{} = 1234
+	var mw2 interface{} = 5678
+	var mw3 interface{} = 9012
+
+	b.Use(mw1)
+	b.Group(func(b Builder) {
+		b.Use(mw2)
+		b.UseFront(mw3)
+		b.Handle("GET", "/", noopHandler)
+	})
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 1) {
+		assert.Equal(t, []types.MiddlewareType{mw1, mw3, mw2}, rd[0].Middleware)
+	}
+}
+
+func TestOptionsAll(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.OptionsAll("/api/")
+	b.Get("/api/users", noopHandler)
+	b.Post("/api/users", noopHandler)
+	b.Delete(regexp.MustCompile(`^/api/users/(\d+)$`), noopHandler)
+	b.Put("/other", noopHandler)
+
+	rd := b.RouteDefs()
+	if !assert.Len(t, rd, 5) {
+		return
+	}
+
+	// The OPTIONS route is registered last.
+	def := rd[4]
+	assert.Equal(t, "OPTIONS", def.Method)
+
+	pat := router.ParsePattern(def.Pattern)
+	r, _ := http.NewRequest("OPTIONS", "/api/anything", nil)
+	assert.True(t, pat.Match(r))
+	r, _ = http.NewRequest("OPTIONS", "/other", nil)
+	assert.False(t, pat.Match(r))
+
+	w := httptest.NewRecorder()
+	router.MakeHandler(def.Handler).ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "DELETE, GET, OPTIONS, POST", w.Header().Get("Allow"))
+}
+
+func TestOptionsAllPseudoMethods(t *testing.T) {
+	t.Parallel()
+
+	// Routes for any method, and NotFound handlers, aren't real methods.
+	b := New()
+	b.OptionsAll("/api/")
+	b.Get("/api/users", noopHandler)
+	b.AnyMethod("/api/proxy/*", noopHandler)
+	b.RouteClean("/api", func(b Builder) {
+		b.NotFound(noopHandler)
+	})
+
+	var def RouteDef
+	for _, d := range b.RouteDefs() {
+		if d.Method == "OPTIONS" {
+			def = d
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("OPTIONS", "/api/users", nil)
+	router.MakeHandler(def.Handler).ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestRouteMeta(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Get("/admin", noopHandler).Meta("scope", "admin").Meta("tier", 2)
+	b.Get("/", noopHandler)
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 2) {
+		assert.Equal(t, map[string]interface{}{
+			"scope": "admin",
+			"tier":  2,
+		}, rd[0].Meta)
+		assert.Nil(t, rd[1].Meta)
+	}
+}
+
+func TestRouteNames(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Get("/", noopHandler).Name("index")
+	b.GroupNamed("admin", func(b Builder) {
+		b.Get("/admin/users", noopHandler).Name("users")
+		b.Group(func(b Builder) {
+			b.Get("/admin/stats", noopHandler).Name("stats")
+		})
+		b.RouteNamed("api", "/admin/api", func(b Builder) {
+			b.Get("/keys", noopHandler).Name("keys")
+			b.Get("/unnamed", noopHandler)
+		})
+	})
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 5) {
+		assert.Equal(t, "index", rd[0].Name)
+		assert.Equal(t, "admin.users", rd[1].Name)
+		assert.Equal(t, "admin.stats", rd[2].Name)
+		assert.Equal(t, "admin.api.keys", rd[3].Name)
+		assert.Equal(t, "/admin/api/keys", rd[3].Pattern)
+		assert.Equal(t, "", rd[4].Name)
+	}
+}
+
+func TestAnyMethod(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.AnyMethod("/", noopHandler)
+
+	rd := b.RouteDefs()
+	if assert.Len(t, rd, 1) {
+		assert.Equal(t, MethodAny, rd[0].Method)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	t.Parallel()
+
+	// Note: these aren't valid middleware, but we don't actually type-check
+	// them in the builder.
+	var mw1 interface{} = 1234
+
+	api := New()
+	api.Use(mw1)
+	api.Get("/users", noopHandler)
+	api.NotFound(noopHandler)
+
+	b := New()
+	b.NotFound(noopHandler)
+	b.Mount("/api", api)
+	b.Get("/", noopHandler)
+
+	rd := b.RouteDefs()
+	if !assert.Len(t, rd, 4) {
+		return
+	}
+
+	// The innermost handler is listed first.
+	assert.Equal(t, MethodNotFound, rd[2].Method)
+	assert.Equal(t, []types.MiddlewareType{mw1}, rd[2].Middleware)
+	assert.Equal(t, MethodNotFound, rd[3].Method)
+	assert.Len(t, rd[3].Middleware, 0)
+
+	var scopeTests = []struct {
+		path  string
+		match bool
+	}{
+		{"/api", true},
+		{"/api/", true},
+		{"/api/missing", true},
+		{"/apix", false},
+		{"/", false},
+	}
+	pat := router.ParsePattern(rd[2].Pattern)
+	for _, test := range scopeTests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		assert.Equal(t, test.match, pat.Match(r), test.path)
+	}
+
+	r, _ := http.NewRequest("GET", "/anything", nil)
+	assert.True(t, router.ParsePattern(rd[3].Pattern).Match(r))
+}
+
+func TestHandleEach(t *testing.T) {
+	t.Parallel()
+
+	resources := []string{"users", "posts", "tags"}
+
+	b := New()
+	configs := HandleEach(b, resources, func(name string) (string, string, types.HandlerType) {
+		return "GET", "/" + name, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	})
+	assert.Len(t, configs, 3)
+	configs[1].Name("posts")
+
+	rd := b.RouteDefs()
+	if !assert.Len(t, rd, 3) {
+		return
+	}
+	assert.Equal(t, "posts", rd[1].Name)
+
+	// Each route has its own handler, bound to its own item.
+	for i, name := range resources {
+		assert.Equal(t, "GET", rd[i].Method)
+		assert.Equal(t, "/"+name, rd[i].Pattern)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/"+name, nil)
+		router.MakeHandler(rd[i].Handler).ServeHTTPC(context.Background(), w, r)
+		assert.Equal(t, name, w.Body.String())
+	}
+}
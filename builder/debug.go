@@ -0,0 +1,48 @@
+package builder
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/router"
+	"github.com/andrew-d/wolf/types"
+)
+
+// MountDebug registers the handlers from net/http/pprof and expvar under the
+// given prefix, wrapped in the given middleware (e.g. to restrict access, since
+// these handlers should generally not be publicly accessible).  With the
+// prefix "/debug", the following routes are registered:
+//
+//	GET  /debug/pprof/          index of the available profiles
+//	GET  /debug/pprof/cmdline   the program's command line
+//	GET  /debug/pprof/profile   CPU profile
+//	GET  /debug/pprof/symbol    symbol lookup (also POST)
+//	GET  /debug/pprof/trace     execution trace
+//	GET  /debug/pprof/:name     named profiles (e.g. "heap", "goroutine")
+//	GET  /debug/vars            exported variables, as JSON
+//
+// The links in the index are relative, so any prefix can be used.  This lives
+// in the builder package, rather than the router package, since the router
+// package can't import this one.
+func MountDebug(b Builder, prefix string, guards ...types.MiddlewareType) {
+	b.Route(prefix, func(b Builder) {
+		for _, mw := range guards {
+			b.Use(mw)
+		}
+
+		b.Get("/pprof/", pprof.Index)
+		b.Get("/pprof/cmdline", pprof.Cmdline)
+		b.Get("/pprof/profile", pprof.Profile)
+		b.Get("/pprof/symbol", pprof.Symbol)
+		b.Post("/pprof/symbol", pprof.Symbol)
+		b.Get("/pprof/trace", pprof.Trace)
+		b.Get("/pprof/:name", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			name, _ := router.GetURLParam(ctx, "name")
+			pprof.Handler(name).ServeHTTP(w, r)
+		})
+		b.Get("/vars", expvar.Handler())
+	})
+}
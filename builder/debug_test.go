@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/andrew-d/wolf/middleware"
+	"github.com/andrew-d/wolf/router"
+)
+
+// serveDefs serves a request with the first of the given routes that matches
+// it, or responds with a 404 if none do.
+func serveDefs(defs []RouteDef, method, path string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+
+	for _, def := range defs {
+		pat := router.ParsePattern(def.Pattern)
+		if def.Method != method || !pat.Match(r) {
+			continue
+		}
+
+		stack := middleware.New(router.MakeHandler(def.Handler).ServeHTTPC, def.Middleware)
+		si := stack.Get()
+		pat.Run(r, &si.Context)
+		si.Handler.ServeHTTP(w, r)
+		stack.Release(si)
+		return w
+	}
+
+	http.NotFound(w, r)
+	return w
+}
+
+func TestMountDebug(t *testing.T) {
+	t.Parallel()
+
+	guard := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Debug-Token") != "secret" {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	b := New()
+	MountDebug(b, "/debug", guard)
+	defs := b.RouteDefs()
+	allowed := http.Header{"X-Debug-Token": {"secret"}}
+
+	w := serveDefs(defs, "GET", "/debug/pprof/", allowed)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+
+	w = serveDefs(defs, "GET", "/debug/pprof/goroutine", allowed)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveDefs(defs, "GET", "/debug/pprof/cmdline", allowed)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveDefs(defs, "GET", "/debug/vars", allowed)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "memstats")
+
+	// The guard applies to every route.
+	w = serveDefs(defs, "GET", "/debug/pprof/", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serveDefs(defs, "GET", "/debug/vars", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
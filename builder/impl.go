@@ -2,7 +2,13 @@ package builder
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/router"
 	"github.com/andrew-d/wolf/types"
 )
 
@@ -11,14 +17,34 @@ var _ = fmt.Println
 type routeSpec struct {
 	method  string
 	handler types.HandlerType
+	name    string
+	meta    map[string]interface{}
 
 	// TODO: future support for per-route middleware would go here
 }
 
+// Name implements RouteConfig.
+func (r *routeSpec) Name(name string) RouteConfig {
+	r.name = name
+	return r
+}
+
+// Meta implements RouteConfig.
+func (r *routeSpec) Meta(key string, val interface{}) RouteConfig {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = val
+	return r
+}
+
 type builderSpec struct {
 	// True if this builder should inherit the middleware from the parent.
 	inherit bool
 
+	// Namespace for the names of routes in this builder, if any.
+	name string
+
 	builder Builder
 }
 
@@ -33,33 +59,96 @@ type routeOrBuilderSpec struct {
 type builder struct {
 	specs      []routeOrBuilderSpec
 	middleware []types.MiddlewareType
+
+	// Name of each middleware, in parallel with the above.
+	names []string
+
+	// Prefixes registered with OptionsAll.
+	optionsAll []string
+
+	// Handler registered with NotFound, if any.
+	notFound types.HandlerType
+
+	// True if routes registered on this builder will be prefixed, and so
+	// must have string patterns.
+	underPrefix bool
+}
+
+// optionsAllSpec is an OptionsAll prefix, along with the middleware that
+// applies to it, found while walking the builders.
+type optionsAllSpec struct {
+	prefix     string
+	middleware []types.MiddlewareType
+	names      []string
+}
+
+// notFoundSpec is a NotFound handler, along with the prefix and middleware
+// that apply to it, found while walking the builders.
+type notFoundSpec struct {
+	prefix     string
+	handler    types.HandlerType
+	middleware []types.MiddlewareType
+	names      []string
 }
 
 func newBuilder() *builder {
 	return &builder{}
 }
 
-func (r *builder) Handle(method string, pattern types.PatternType, handler types.HandlerType) {
+func (r *builder) Handle(method string, pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	if _, ok := pattern.(string); r.underPrefix && !ok {
+		panic(unprefixable(pattern))
+	}
+
+	route := &routeSpec{
+		method:  method,
+		handler: handler,
+	}
 	r.specs = append(r.specs, routeOrBuilderSpec{
 		pattern: pattern,
-		route: &routeSpec{
-			method:  method,
-			handler: handler,
-		},
+		route:   route,
 	})
+	return route
 }
 
 func (r *builder) Use(m types.MiddlewareType) {
+	r.UseNamed("", m)
+}
+
+func (r *builder) UseNamed(name string, m types.MiddlewareType) {
 	r.middleware = append(r.middleware, m)
+	r.names = append(r.names, name)
+}
+
+func (r *builder) UseFront(m types.MiddlewareType) {
+	r.middleware = append([]types.MiddlewareType{m}, r.middleware...)
+	r.names = append([]string{""}, r.names...)
 }
 
 func (r *builder) Group(fn func(r Builder)) {
 	r.Route("", fn)
 }
 
+func (r *builder) GroupNamed(name string, fn func(r Builder)) {
+	r.RouteNamed(name, "", fn)
+}
+
 func (r *builder) Route(pattern string, fn func(r Builder)) {
+	r.route("", pattern, true, fn)
+}
+
+func (r *builder) RouteClean(pattern string, fn func(r Builder)) {
+	r.route("", pattern, false, fn)
+}
+
+func (r *builder) RouteNamed(name, pattern string, fn func(r Builder)) {
+	r.route(name, pattern, true, fn)
+}
+
+func (r *builder) route(name, pattern string, inherit bool, fn func(r Builder)) {
 	// Create a new builder.
 	sub := newBuilder()
+	sub.underPrefix = r.underPrefix || pattern != ""
 
 	// Call the function in order to register things.
 	fn(sub)
@@ -68,7 +157,8 @@ func (r *builder) Route(pattern string, fn func(r Builder)) {
 	r.specs = append(r.specs, routeOrBuilderSpec{
 		pattern: pattern,
 		subBuilder: &builderSpec{
-			inherit: true,
+			inherit: inherit,
+			name:    name,
 			builder: sub,
 		},
 	})
@@ -77,22 +167,44 @@ func (r *builder) Route(pattern string, fn func(r Builder)) {
 func (r *builder) Mount(pattern string, sr Builder) {
 	// Append this builder to our specifications array, but explicitly mark it
 	// as 'not inheriting'.
+	r.mount(pattern, false, sr)
+}
+
+func (r *builder) MountInherit(pattern string, sr Builder) {
+	// The mounted builder inherits our middleware, which will run before its
+	// own.
+	r.mount(pattern, true, sr)
+}
+
+func (r *builder) mount(pattern string, inherit bool, sr Builder) {
 	r.specs = append(r.specs, routeOrBuilderSpec{
 		pattern: pattern,
 		subBuilder: &builderSpec{
-			inherit: false,
+			inherit: inherit,
 			builder: sr,
 		},
 	})
 }
 
+func (r *builder) OptionsAll(prefix string) {
+	r.optionsAll = append(r.optionsAll, prefix)
+}
+
+func (r *builder) NotFound(handler types.HandlerType) {
+	r.notFound = handler
+}
+
 func (r *builder) RouteDefs() []RouteDef {
 	defs := []RouteDef{}
 	seen := map[*builder]struct{}{}
+	var (
+		options   []optionsAllSpec
+		notFounds []notFoundSpec
+	)
 
 	// Recursively traverse the routes array.
-	var walk func(*builder, []types.MiddlewareType)
-	walk = func(b *builder, middleware []types.MiddlewareType) {
+	var walk func(*builder, string, string, []types.MiddlewareType, []string)
+	walk = func(b *builder, prefix, namespace string, middleware []types.MiddlewareType, names []string) {
 		// If we've seen this builder before, then we've hit a cycle.
 		if _, ok := seen[b]; ok {
 			msg := fmt.Sprintf(`Cycle detected while traversing router: saw `+
@@ -101,20 +213,44 @@ func (r *builder) RouteDefs() []RouteDef {
 		}
 		seen[b] = struct{}{}
 
+		for _, optPrefix := range b.optionsAll {
+			mware := make([]types.MiddlewareType, 0, len(middleware)+len(b.middleware))
+			mware = append(append(mware, middleware...), b.middleware...)
+			mnames := make([]string, 0, cap(mware))
+			mnames = append(append(mnames, names...), b.names...)
+
+			options = append(options, optionsAllSpec{prefix + optPrefix, mware, namesIfAny(mnames)})
+		}
+
+		if b.notFound != nil {
+			mware := make([]types.MiddlewareType, 0, len(middleware)+len(b.middleware))
+			mware = append(append(mware, middleware...), b.middleware...)
+			mnames := make([]string, 0, cap(mware))
+			mnames = append(append(mnames, names...), b.names...)
+
+			notFounds = append(notFounds, notFoundSpec{prefix, b.notFound, mware, namesIfAny(mnames)})
+		}
+
 		// Walk the specs in this builder.
 		for _, spec := range b.specs {
 			mware := make([]types.MiddlewareType, 0, len(middleware)+len(b.middleware))
+			mnames := make([]string, 0, cap(mware))
 
 			// Simple case - this is a route specification.  Copy the spec.
 			if spec.route != nil {
 				mware = append(mware, middleware...)
 				mware = append(mware, b.middleware...)
+				mnames = append(mnames, names...)
+				mnames = append(mnames, b.names...)
 
 				defs = append(defs, RouteDef{
-					Method:     spec.route.method,
-					Pattern:    spec.pattern,
-					Handler:    spec.route.handler,
-					Middleware: mware,
+					Method:          spec.route.method,
+					Pattern:         prefixed(prefix, spec.pattern),
+					Handler:         spec.route.handler,
+					Middleware:      mware,
+					MiddlewareNames: namesIfAny(mnames),
+					Name:            qualify(namespace, spec.route.name),
+					Meta:            spec.route.meta,
 				})
 			} else if spec.subBuilder != nil {
 				// If this builder inherits, then we copy the middleware -
@@ -123,60 +259,255 @@ func (r *builder) RouteDefs() []RouteDef {
 				if spec.subBuilder.inherit {
 					mware = append(mware, middleware...)
 					mware = append(mware, b.middleware...)
+					mnames = append(mnames, names...)
+					mnames = append(mnames, b.names...)
 				}
 
 				// TODO: do we always have the same builder type?
 				sb := spec.subBuilder.builder.(*builder)
 
-				// Recurse into the sub-builder.
-				walk(sb, mware)
+				// Unnamed builders don't add to the namespace.
+				ns := namespace
+				if spec.subBuilder.name != "" {
+					ns = qualify(namespace, spec.subBuilder.name)
+				}
+
+				// Recurse into the sub-builder, which adds its pattern to
+				// the prefix of everything underneath it.
+				walk(sb, prefix+spec.pattern.(string), ns, mware, mnames)
 			} else {
 				panic("BUG: neither route or builder")
 			}
 		}
 	}
 
-	walk(r, nil)
+	walk(r, "", "", nil, nil)
+
+	// Add the OPTIONS handlers last, once we know every route.
+	routes := defs
+	for _, spec := range options {
+		defs = append(defs, RouteDef{
+			Method:          "OPTIONS",
+			Pattern:         prefixPattern(spec.prefix),
+			Handler:         allowHandler(methodsUnder(routes, spec.prefix)),
+			Middleware:      spec.middleware,
+			MiddlewareNames: spec.names,
+		})
+	}
+
+	// Add the NotFound handlers after that, innermost first.
+	sort.SliceStable(notFounds, func(i, j int) bool {
+		return len(notFounds[i].prefix) > len(notFounds[j].prefix)
+	})
+	for _, spec := range notFounds {
+		defs = append(defs, RouteDef{
+			Method:          MethodNotFound,
+			Pattern:         newScopePattern(spec.prefix),
+			Handler:         spec.handler,
+			Middleware:      spec.middleware,
+			MiddlewareNames: spec.names,
+		})
+	}
 
 	return defs
 }
 
+// methodsUnder returns the sorted union of the methods of all the given routes
+// whose patterns fall under the given prefix, along with OPTIONS.  String
+// patterns are compared directly, while other patterns are compared using their
+// Prefix method.  MethodAny and MethodNotFound aren't real methods, and so are
+// never included.
+func methodsUnder(defs []RouteDef, prefix string) []string {
+	set := map[string]struct{}{"OPTIONS": {}}
+	for _, def := range defs {
+		if def.Method == MethodAny || def.Method == MethodNotFound {
+			continue
+		}
+
+		var pat string
+		if s, ok := def.Pattern.(string); ok {
+			pat = s
+		} else {
+			pat = router.ParsePattern(def.Pattern).Prefix()
+		}
+
+		if strings.HasPrefix(pat, prefix) {
+			set[def.Method] = struct{}{}
+		}
+	}
+
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// prefixPattern matches every path under a prefix registered with OptionsAll.
+type prefixPattern string
+
+func (p prefixPattern) Prefix() string {
+	return ""
+}
+
+func (p prefixPattern) Match(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, string(p))
+}
+
+func (p prefixPattern) Run(r *http.Request, c *context.Context) {
+}
+
+func (p prefixPattern) String() string {
+	return fmt.Sprintf("prefixPattern(%q)", string(p))
+}
+
+// scopePattern matches a prefix, along with every path in the subtree
+// underneath it.  Unlike prefixPattern, the prefix must end at a path segment
+// boundary, and may contain parameters.
+type scopePattern struct {
+	prefix  string
+	exact   router.StringPattern
+	subtree router.StringPattern
+}
+
+func newScopePattern(prefix string) scopePattern {
+	return scopePattern{
+		prefix:  prefix,
+		exact:   router.ParseStringPattern(prefix),
+		subtree: router.ParseStringPattern(strings.TrimSuffix(prefix, "/") + "/*"),
+	}
+}
+
+func (p scopePattern) Prefix() string {
+	return ""
+}
+
+func (p scopePattern) Match(r *http.Request) bool {
+	return p.prefix == "" || p.exact.Match(r) || p.subtree.Match(r)
+}
+
+func (p scopePattern) Run(r *http.Request, c *context.Context) {
+}
+
+// Specificity implements router.SpecificPattern, so that routers can prefer
+// the scope with the longest prefix, even across several builders.
+func (p scopePattern) Specificity() int {
+	return len(p.prefix)
+}
+
+func (p scopePattern) String() string {
+	return fmt.Sprintf("scopePattern(%q)", p.prefix)
+}
+
+// allowHandler returns a handler that responds with the given methods in an
+// Allow header.
+func allowHandler(methods []string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// prefixed adds the given prefix to a route's pattern.  Only string patterns
+// can be prefixed, so this panics if given any other type of pattern, unless
+// the prefix is empty.
+func prefixed(prefix string, pattern types.PatternType) types.PatternType {
+	if prefix == "" {
+		return pattern
+	}
+	if s, ok := pattern.(string); ok {
+		return prefix + s
+	}
+	panic(unprefixable(pattern))
+}
+
+// unprefixable returns the message to panic with when a route with the given
+// pattern is registered under a prefix.
+func unprefixable(pattern types.PatternType) string {
+	return fmt.Sprintf("builder: pattern %v (%T) can not be prefixed, since "+
+		"only string patterns can be registered under a prefix", pattern, pattern)
+}
+
+// qualify adds the given namespace to a name.  Empty names stay empty.
+func qualify(namespace, name string) string {
+	if namespace == "" || name == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// namesIfAny returns the given middleware names, or nil if none of the
+// middleware were named.
+func namesIfAny(names []string) []string {
+	for _, name := range names {
+		if name != "" {
+			return names
+		}
+	}
+	return nil
+}
+
+// HandleEach registers a route for each of the given items, using the method,
+// pattern and handler that fn returns for it.  The routes are registered in
+// the order of the items, and their RouteConfigs are returned in the same
+// order.
+//
+// This is equivalent to calling Handle in a loop, but since each item is
+// passed to fn as an argument, a handler created by fn as a closure captures
+// its own item, rather than a loop variable shared by every iteration.  It is
+// a function, rather than a method of Builder, since methods can't have type
+// parameters.
+func HandleEach[T any](b Builder, items []T, fn func(T) (method string, pattern string, handler types.HandlerType)) []RouteConfig {
+	configs := make([]RouteConfig, 0, len(items))
+	for _, item := range items {
+		method, pattern, handler := fn(item)
+		configs = append(configs, b.Handle(method, pattern, handler))
+	}
+	return configs
+}
+
+func (r *builder) AnyMethod(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle(MethodAny, pattern, handler)
+}
+
 // Helper functions below here
 
-func (r *builder) Connect(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("CONNECT", pattern, handler)
+func (r *builder) Connect(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("CONNECT", pattern, handler)
 }
 
-func (r *builder) Delete(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("DELETE", pattern, handler)
+func (r *builder) Delete(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("DELETE", pattern, handler)
 }
 
-func (r *builder) Get(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("GET", pattern, handler)
+func (r *builder) Get(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("GET", pattern, handler)
 }
 
-func (r *builder) Head(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("HEAD", pattern, handler)
+func (r *builder) Head(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("HEAD", pattern, handler)
 }
 
-func (r *builder) Options(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("OPTIONS", pattern, handler)
+func (r *builder) Options(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("OPTIONS", pattern, handler)
 }
 
-func (r *builder) Patch(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("PATCH", pattern, handler)
+func (r *builder) Patch(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("PATCH", pattern, handler)
 }
 
-func (r *builder) Post(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("POST", pattern, handler)
+func (r *builder) Post(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("POST", pattern, handler)
 }
 
-func (r *builder) Put(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("PUT", pattern, handler)
+func (r *builder) Put(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("PUT", pattern, handler)
 }
 
-func (r *builder) Trace(pattern types.PatternType, handler types.HandlerType) {
-	r.Handle("TRACE", pattern, handler)
+func (r *builder) Trace(pattern types.PatternType, handler types.HandlerType) RouteConfig {
+	return r.Handle("TRACE", pattern, handler)
 }
 
 var _ Builder = &builder{}
@@ -0,0 +1,68 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/andrew-d/wolf/router"
+)
+
+// ToOpenAPIPaths converts the given route definitions into the skeleton of an
+// OpenAPI "paths" object.  Each key is a route's pattern as a path template
+// (e.g. "/users/:id" becomes "/users/{id}"), and each value maps the
+// lower-cased methods registered for that path to an operation object.
+//
+// Operations contain an "operationId" (if the route was named), a "parameters"
+// list describing each path parameter, and an "x-meta" entry holding any
+// metadata attached to the route.  No schemas are generated; the result is
+// meant as a starting point for a complete description.
+//
+// Only string patterns can be expressed as path templates, so routes with
+// other patterns (e.g. regular expressions) are skipped, as are routes
+// registered with AnyMethod or NotFound.  This lives in the builder package,
+// rather than the router package, since the router package can't import this
+// one.
+func ToOpenAPIPaths(defs []RouteDef) map[string]map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, def := range defs {
+		if def.Method == MethodAny || def.Method == MethodNotFound {
+			continue
+		}
+
+		var pat router.StringPattern
+		switch p := def.Pattern.(type) {
+		case string:
+			pat = router.ParseStringPattern(p)
+		case router.StringPattern:
+			pat = p
+		default:
+			continue
+		}
+
+		op := make(map[string]interface{})
+		if def.Name != "" {
+			op["operationId"] = def.Name
+		}
+		if names := pat.ParamNames(); len(names) > 0 {
+			params := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				params = append(params, map[string]interface{}{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+			op["parameters"] = params
+		}
+		if def.Meta != nil {
+			op["x-meta"] = def.Meta
+		}
+
+		tmpl := pat.Template()
+		if paths[tmpl] == nil {
+			paths[tmpl] = make(map[string]interface{})
+		}
+		paths[tmpl][strings.ToLower(def.Method)] = op
+	}
+	return paths
+}
@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToOpenAPIPaths(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Get("/users", noopHandler).Name("listUsers")
+	b.Route("/users/:id", func(b Builder) {
+		b.Get("", noopHandler).Name("getUser").Meta("scope", "read")
+		b.Delete("", noopHandler)
+	})
+	b.Get(regexp.MustCompile(`^/legacy/(\d+)$`), noopHandler)
+	b.AnyMethod("/proxy/*", noopHandler)
+
+	idParam := map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+	assert.Equal(t, map[string]map[string]interface{}{
+		"/users": {
+			"get": map[string]interface{}{"operationId": "listUsers"},
+		},
+		"/users/{id}": {
+			"get": map[string]interface{}{
+				"operationId": "getUser",
+				"parameters":  []interface{}{idParam},
+				"x-meta":      map[string]interface{}{"scope": "read"},
+			},
+			"delete": map[string]interface{}{
+				"parameters": []interface{}{idParam},
+			},
+		},
+	}, ToOpenAPIPaths(b.RouteDefs()))
+}
@@ -0,0 +1,175 @@
+package builder
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/andrew-d/wolf/middleware"
+	"github.com/andrew-d/wolf/router"
+)
+
+// muxPattern translates a string pattern into an equivalent http.ServeMux
+// pattern, returning false if ServeMux is unable to express it.
+func muxPattern(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, "/") {
+		return "", false
+	}
+
+	// ServeMux treats braces as wildcards, and has no way to escape them.
+	if strings.ContainsAny(raw, "{}") {
+		return "", false
+	}
+
+	// ServeMux separates the method from the path with whitespace, so a path
+	// can't contain any.
+	if strings.IndexFunc(raw, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) >= 0 {
+		return "", false
+	}
+
+	// A trailing wildcard is equivalent to a ServeMux subtree pattern.
+	base, wildcard := raw, false
+	if router.ParseStringPattern(raw).HasWildcard() {
+		base, wildcard = raw[:strings.LastIndex(raw, "/*")+1], true
+	}
+
+	// ServeMux has no equivalent of parameters.
+	if !router.ParseStringPattern(base).IsStatic() {
+		return "", false
+	}
+
+	// ServeMux redirects requests for paths with repeated slashes to the
+	// cleaned path, so such a pattern could never be matched.
+	if strings.Contains(base, "//") {
+		return "", false
+	}
+
+	// ServeMux treats every pattern ending in a slash as a subtree, so we can
+	// not express an exact match on such a path.
+	if !wildcard && strings.HasSuffix(base, "/") {
+		return "", false
+	}
+
+	return base, true
+}
+
+// muxHandler adapts a route into a http.Handler that can be registered on a
+// ServeMux.
+type muxHandler struct {
+	method string
+	mware  *middleware.MiddlewareStack
+}
+
+// allows returns whether the route can serve a request with the given method.
+// As with http.ServeMux's own method patterns, GET routes also serve HEAD
+// requests.
+func (h muxHandler) allows(method string) bool {
+	switch h.method {
+	case MethodAny, method:
+		return true
+	case "GET":
+		return method == "HEAD"
+	}
+	return false
+}
+
+func (h muxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// ServeMux doesn't dispatch on the method, so we do it here.
+	if !h.allows(r.Method) {
+		allow := h.method
+		if allow == "GET" {
+			allow = "GET, HEAD"
+		}
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	stack := h.mware.Get()
+	stack.Handler.ServeHTTP(w, r)
+	h.mware.Release(stack)
+}
+
+// ToServeMux registers all routes from the given builder on a new
+// http.ServeMux, including each route's middleware.  This is intended to aid
+// interoperability with code that expects a ServeMux.
+//
+// Since ServeMux is considerably less capable than the routers in this
+// package, only a subset of routes can be converted.  In particular:
+//
+//   - Patterns must be strings without any parameters, braces or whitespace.
+//     Regexp patterns and other Pattern implementations are not supported.
+//   - A trailing wildcard is converted into a ServeMux subtree pattern (e.g.
+//     "/files/*" becomes "/files/"), but the unmatched tail is not bound.
+//   - Patterns ending in a slash without a wildcard are not supported, since
+//     ServeMux always treats them as subtrees.
+//   - Each path may only be registered for a single method, since ServeMux
+//     does not dispatch on the method.  Requests with any other method will
+//     receive a 405 Method Not Allowed response, except that GET routes also
+//     serve HEAD requests, and routes registered with AnyMethod serve every
+//     method.
+//   - Handlers registered with NotFound and OptionsAll are skipped, since
+//     ServeMux can not express them.
+//
+// An error is returned if any route cannot be converted.
+func ToServeMux(b Builder) (*http.ServeMux, error) {
+	mux := http.NewServeMux()
+	seen := make(map[string]string)
+
+	for _, def := range b.RouteDefs() {
+		if def.Method == MethodNotFound {
+			continue
+		}
+		if _, ok := def.Pattern.(prefixPattern); ok {
+			continue
+		}
+
+		raw, ok := def.Pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("builder: pattern %v (%T) can not be "+
+				"converted to a ServeMux pattern", def.Pattern, def.Pattern)
+		}
+
+		pat, ok := muxPattern(raw)
+		if !ok {
+			return nil, fmt.Errorf("builder: pattern %q can not be "+
+				"converted to a ServeMux pattern", raw)
+		}
+
+		if method, ok := seen[pat]; ok {
+			return nil, fmt.Errorf("builder: pattern %q is registered for "+
+				"both %s and %s", raw, method, def.Method)
+		}
+		seen[pat] = def.Method
+
+		handler := router.MakeHandler(def.Handler)
+		err := handleMux(mux, pat, muxHandler{
+			method: def.Method,
+			mware:  middleware.New(handler.ServeHTTPC, def.Middleware),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("builder: pattern %q can not be "+
+				"registered on a ServeMux: %v", raw, err)
+		}
+	}
+
+	return mux, nil
+}
+
+// handleMux registers a handler on a ServeMux, returning an error instead of
+// panicking if the ServeMux rejects the pattern.  muxPattern should already
+// have rejected anything that ServeMux can't parse, so this is only a
+// safeguard.
+func handleMux(mux *http.ServeMux, pat string, h http.Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	mux.Handle(pat, h)
+	return nil
+}
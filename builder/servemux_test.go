@@ -0,0 +1,139 @@
+package builder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxPattern(t *testing.T) {
+	t.Parallel()
+
+	var muxPatternTests = []struct {
+		raw string
+		pat string
+		ok  bool
+	}{
+		{"/hello", "/hello", true},
+		{"/hello/world.json", "/hello/world.json", true},
+		{"/files/*", "/files/", true},
+		{"/files/*path", "/files/", true},
+		{"/*", "/", true},
+		{"/files/*.txt", "/files/*.txt", true},
+		{"/a:b", "/a:b", true},
+		{"/hello/", "", false},
+		{"/", "", false},
+		{"/users/:id", "", false},
+		{"/users/:id/*", "", false},
+		{"//double", "", false},
+		{"/{name}", "", false},
+		{"/files/{path...}", "", false},
+		{"/brace}", "", false},
+		{"/a b", "", false},
+		{"/a\tb", "", false},
+		{"/a\nb", "", false},
+		{"relative", "", false},
+	}
+
+	for _, test := range muxPatternTests {
+		pat, ok := muxPattern(test.raw)
+		assert.Equal(t, test.ok, ok, test.raw)
+		assert.Equal(t, test.pat, pat, test.raw)
+	}
+}
+
+func TestToServeMux(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	b := New()
+	b.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "middleware")
+			h.ServeHTTP(w, r)
+		})
+	})
+	b.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "hello")
+	})
+	b.Post("/files/*", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "files")
+	})
+	b.AnyMethod("/any", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "any")
+	})
+
+	// These can't be expressed on a ServeMux, and are skipped.
+	b.OptionsAll("/")
+	b.NotFound(noopHandler)
+
+	mux, err := ToServeMux(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var allow string
+	send := func(method, url string) int {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, url, nil)
+		mux.ServeHTTP(w, r)
+		allow = w.Header().Get("Allow")
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("GET", "/hello"))
+	assert.Equal(t, http.StatusOK, send("POST", "/files/a/b"))
+	assert.Equal(t, http.StatusNotFound, send("GET", "/missing"))
+	assert.Equal(t, []string{"middleware", "hello", "middleware", "files"}, calls)
+
+	// GET routes also serve HEAD, and AnyMethod routes serve everything.
+	calls = nil
+	assert.Equal(t, http.StatusOK, send("HEAD", "/hello"))
+	assert.Equal(t, http.StatusOK, send("PUT", "/any"))
+	assert.Equal(t, http.StatusOK, send("PROPFIND", "/any"))
+	assert.Equal(t, []string{"middleware", "hello", "middleware", "any", "middleware", "any"}, calls)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, send("POST", "/hello"))
+	assert.Equal(t, "GET, HEAD", allow)
+	assert.Equal(t, http.StatusMethodNotAllowed, send("GET", "/files/a"))
+	assert.Equal(t, "POST", allow)
+}
+
+func TestToServeMuxErrors(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Get("/users/:id", noopHandler)
+	_, err := ToServeMux(b)
+	assert.Error(t, err)
+
+	b = New()
+	b.Get(regexp.MustCompile("^/users$"), noopHandler)
+	_, err = ToServeMux(b)
+	assert.Error(t, err)
+
+	b = New()
+	b.Get("/users", noopHandler)
+	b.Post("/users", noopHandler)
+	_, err = ToServeMux(b)
+	assert.Error(t, err)
+
+	// Patterns that ServeMux can't parse are reported, rather than panicking.
+	b = New()
+	b.Get("/a b", noopHandler)
+	assert.NotPanics(t, func() {
+		_, err = ToServeMux(b)
+	})
+	assert.Error(t, err)
+}
+
+func TestHandleMux(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	assert.NoError(t, handleMux(mux, "/a", http.NotFoundHandler()))
+	assert.Error(t, handleMux(mux, "/a", http.NotFoundHandler()))
+}
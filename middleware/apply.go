@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/types"
+)
+
+// Apply wraps the given final function in a single middleware, and returns the
+// resulting handler.  It is mostly useful for unit-testing a middleware in
+// isolation, without needing to manage a MiddlewareStack:
+//
+//	h := middleware.Apply(MyMiddleware, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+//		// Check what the middleware did to the context...
+//	})
+//	h.ServeHTTP(httptest.NewRecorder(), req)
+//
+// Unlike a MiddlewareStack, nothing is cached, so the middleware is applied
+// afresh (with a new Background context) for every request.  Apply panics if
+// the middleware is not a valid MiddlewareType.
+func Apply(mw types.MiddlewareType, final FinalFunc) http.Handler {
+	fn := makeCanonical(mw)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.Background()
+		h := fn(&ctx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			final(ctx, w, r)
+		}))
+		h.ServeHTTP(w, r)
+	})
+}
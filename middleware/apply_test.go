@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	var id string
+	h := Apply(RequestID(), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		id = GetRequestID(ctx)
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	assert.NotEqual(t, "", id)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	// Each request gets a fresh context.
+	first := id
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.NotEqual(t, first, id)
+
+	// Plain middleware work too.
+	var called bool
+	h = Apply(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			h.ServeHTTP(w, r)
+		})
+	}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, called)
+
+	// Invalid middleware panic immediately.
+	assert.Panics(t, func() {
+		Apply(1234, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	})
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControlConfig configures the behaviour of the CacheControl middleware.
+type CacheControlConfig struct {
+	// Directive is the value of the Cache-Control header to set, e.g.
+	// "public, max-age=3600".
+	Directive string
+
+	// SetExpires, if true, also sets the Expires header to the time at
+	// which the response becomes stale, as given by the max-age directive,
+	// for the benefit of HTTP/1.0 caches.  It has no effect if the directive
+	// doesn't include a max-age.
+	SetExpires bool
+}
+
+// maxAge returns the value of the max-age directive in the given Cache-Control
+// header value, or false if there isn't one.
+func maxAge(directive string) (time.Duration, bool) {
+	for _, part := range strings.Split(directive, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(strings.ToLower(part), "max-age=") {
+			continue
+		}
+
+		secs, err := strconv.Atoi(part[len("max-age="):])
+		if err != nil || secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
+// cacheControlWriter sets caching headers when the response is written,
+// unless the handler has already set them.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	directive   string
+	expires     time.Duration
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		h := w.Header()
+		if h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", w.directive)
+			if w.expires >= 0 && h.Get("Expires") == "" {
+				h.Set("Expires", time.Now().Add(w.expires).UTC().Format(http.TimeFormat))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CacheControl returns a middleware that sets the Cache-Control header of
+// responses to the given directive (e.g. "public, max-age=3600").  It is
+// intended to be used on individual routes or groups, so that responses that
+// can be cached are, while others aren't.  A Cache-Control header set by the
+// handler itself is left alone.
+func CacheControl(directive string) func(http.Handler) http.Handler {
+	return CacheControlWithConfig(CacheControlConfig{Directive: directive})
+}
+
+// CacheControlWithConfig is like CacheControl, but with the given
+// configuration.
+func CacheControlWithConfig(config CacheControlConfig) func(http.Handler) http.Handler {
+	expires := time.Duration(-1)
+	if config.SetExpires {
+		if age, ok := maxAge(config.Directive); ok {
+			expires = age
+		}
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(&cacheControlWriter{
+				ResponseWriter: w,
+				directive:      config.Directive,
+				expires:        expires,
+			}, r)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMaxAge(t *testing.T) {
+	t.Parallel()
+
+	var maxAgeTests = []struct {
+		directive string
+		age       time.Duration
+		ok        bool
+	}{
+		{"public, max-age=3600", time.Hour, true},
+		{"Max-Age=60", time.Minute, true},
+		{"no-store", 0, false},
+		{"max-age=soon", 0, false},
+		{"s-maxage=60", 0, false},
+	}
+	for _, test := range maxAgeTests {
+		age, ok := maxAge(test.directive)
+		assert.Equal(t, test.ok, ok, test.directive)
+		assert.Equal(t, test.age, age, test.directive)
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	t.Parallel()
+
+	var handlerCC string
+	final := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if handlerCC != "" {
+			w.Header().Set("Cache-Control", handlerCC)
+		}
+		w.Write([]byte("body"))
+	}
+
+	send := func(h http.Handler) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	h := Apply(CacheControl("public, max-age=3600"), final)
+	w := send(h)
+	assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "", w.Header().Get("Expires"))
+	assert.Equal(t, "body", w.Body.String())
+
+	// Expires is computed from max-age.
+	h = Apply(CacheControlWithConfig(CacheControlConfig{
+		Directive:  "public, max-age=3600",
+		SetExpires: true,
+	}), final)
+	w = send(h)
+	expires, err := http.ParseTime(w.Header().Get("Expires"))
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 5*time.Second)
+	}
+
+	// The handler's own header takes precedence.
+	handlerCC = "no-store"
+	w = send(h)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "", w.Header().Get("Expires"))
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// CleanPathConfig configures the behaviour of the CleanPath middleware.
+type CleanPathConfig struct {
+	// Redirect, if true, causes GET and HEAD requests for unclean paths to
+	// be redirected to the cleaned path with a 301 Moved Permanently, rather
+	// than being rewritten.  Requests with other methods are always
+	// rewritten, since clients won't necessarily repeat them after a
+	// redirect.
+	Redirect bool
+}
+
+// cleanPath returns the canonical form of a path, as with path.Clean, but
+// keeping any trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// CleanPath returns a middleware that cleans the path of each request before
+// passing it on: runs of slashes are collapsed, and "." and ".." elements are
+// resolved, as with path.Clean.  For example, "/a//b", "/a/../b" and "/a/./b"
+// are cleaned to "/a/b", "/b" and "/a/b" respectively.  When used as global
+// middleware on a router, this runs before the router matches the request.
+//
+// Unlike path.Clean, a trailing slash is kept, so that "/a/" and "/a" remain
+// distinct paths.  Since cleaning operates on the unescaped path, the
+// request's RawPath is cleared when the path is changed.  The original request
+// is not modified.
+func CleanPath() func(http.Handler) http.Handler {
+	return CleanPathWithConfig(CleanPathConfig{})
+}
+
+// CleanPathWithConfig is like CleanPath, but with the given configuration.
+func CleanPathWithConfig(config CleanPathConfig) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cleaned := cleanPath(r.URL.Path)
+			if cleaned == r.URL.Path {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if config.Redirect && (r.Method == "GET" || r.Method == "HEAD") {
+				u := url.URL{Path: cleaned, RawQuery: r.URL.RawQuery}
+				http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+				return
+			}
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = cleaned
+			r2.URL.RawPath = ""
+			h.ServeHTTP(w, r2)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestCleanPath(t *testing.T) {
+	t.Parallel()
+
+	var cleanPathTests = []struct {
+		in  string
+		out string
+	}{
+		{"/a//b", "/a/b"},
+		{"/a/../b", "/b"},
+		{"/a/./b", "/a/b"},
+		{"/a/b/", "/a/b/"},
+		{"/a//b//", "/a/b/"},
+		{"/a/.", "/a"},
+		{"/..", "/"},
+		{"/", "/"},
+		{"", "/"},
+	}
+	for _, test := range cleanPathTests {
+		assert.Equal(t, test.out, cleanPath(test.in), test.in)
+	}
+}
+
+func TestCleanPathMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var path string
+	final := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}
+
+	send := func(h http.Handler, method, url string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// By default, paths are rewritten.
+	h := Apply(CleanPath(), final)
+	w := send(h, "GET", "/a//b")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/a/b", path)
+	send(h, "GET", "/a/../b")
+	assert.Equal(t, "/b", path)
+
+	// Redirects are only sent for GET and HEAD requests.
+	h = Apply(CleanPathWithConfig(CleanPathConfig{Redirect: true}), final)
+	path = ""
+	w = send(h, "GET", "/a/./b?x=1")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/a/b?x=1", w.Header().Get("Location"))
+	assert.Equal(t, "", path)
+
+	w = send(h, "POST", "/a/./b")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/a/b", path)
+
+	// Clean paths are untouched.
+	w = send(h, "GET", "/a/b/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/a/b/", path)
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/types"
+)
+
+// guard returns a handler that only calls the given handler if no response
+// has been written yet.
+func guard(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ww, ok := w.(WrapResponseWriter); ok && ww.Written() {
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Compose combines the given middleware into a single middleware, which
+// applies them in order (i.e. the first middleware given is the outermost).
+// It panics if any of the given middleware is not a valid MiddlewareType.
+//
+// In addition, the composed middleware stops the request from proceeding any
+// further once a response has been written.  If one of the middleware writes a
+// response but still calls the next handler, the remaining middleware (and the
+// handler being wrapped) are skipped, avoiding writing a second response.
+//
+// This works by wrapping the ResponseWriter with WrapWriter, unless it has
+// already been wrapped, and then checking it between each middleware.  As
+// such, a middleware that passes a different ResponseWriter to the next
+// handler will prevent writes before it from being detected.
+func Compose(mws ...types.MiddlewareType) func(*context.Context, http.Handler) http.Handler {
+	funcs := make([]canonicalMiddleware, len(mws))
+	for i, mw := range mws {
+		funcs[i] = makeCanonical(mw)
+	}
+
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		for i := len(funcs) - 1; i >= 0; i-- {
+			h = funcs[i](ctx, guard(h))
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(WrapResponseWriter); !ok {
+				w = WrapWriter(w)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
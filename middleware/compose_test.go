@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestCompose(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	maker := func(name string, write bool) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				if write {
+					w.WriteHeader(http.StatusForbidden)
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	final, run := makeFinalFunc()
+	stack := New(final, nil)
+	stack.Push(Compose(
+		maker("one", false),
+		func(ctx *context.Context, h http.Handler) http.Handler {
+			return maker("two", false)(h)
+		},
+	))
+
+	// Everything runs in order.
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+	assert.True(t, *run)
+	assert.Equal(t, []string{"one", "two"}, calls)
+
+	// Middleware after a written response are skipped.
+	*run = false
+	calls = nil
+	stack = New(final, nil)
+	stack.Push(Compose(maker("one", true), maker("two", false)))
+
+	si = stack.Get()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	si.Handler.ServeHTTP(w, r)
+	stack.Release(si)
+	assert.False(t, *run)
+	assert.Equal(t, []string{"one"}, calls)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestComposeInvalid(t *testing.T) {
+	t.Parallel()
+
+	assert.Panics(t, func() {
+		Compose(func(i int) int { return i + 1 })
+	})
+}
@@ -0,0 +1,13 @@
+package middleware
+
+// contextKey is the type of all keys that this package stores in a Context.
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	featureFlagsKey
+	shutdownKey
+	timingKey
+	clientIPKey
+	localeKey
+)
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// parseDeadline parses the value of a deadline header, which may either be a
+// duration (e.g. "1.5s") or an RFC 3339 timestamp, into the amount of time
+// remaining from now.  It returns false if the value is missing or invalid.  A
+// deadline that has already passed gives zero.
+func parseDeadline(val string, now time.Time) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return 0, false
+		}
+		d = t.Sub(now)
+	}
+
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// DeadlineFromHeader returns a middleware that reads a deadline from the given
+// request header and applies it to the request's context.  The header may
+// contain either a duration (as accepted by time.ParseDuration) or an RFC 3339
+// timestamp.  Since the header is supplied by the client, the resulting
+// timeout is capped at limit, unless limit is zero or negative, in which case
+// the client's timeout is used as it is.  A deadline that has already passed
+// (e.g. "0s") is still applied, so the handler sees an expired context.
+//
+// The context with the deadline is also attached to the request passed to the
+// next handler, so that handlers and middleware that use r.Context() (rather
+// than the wolf context) are cancelled when the deadline expires.
+//
+// Requests with a missing or invalid header are passed through unchanged,
+// with no deadline applied.
+func DeadlineFromHeader(header string, limit time.Duration) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, ok := parseDeadline(r.Header.Get(header), time.Now())
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if limit > 0 && d > limit {
+				d = limit
+			}
+
+			var cancel context.CancelFunc
+			*ctx, cancel = context.WithTimeout(*ctx, d)
+			defer cancel()
+
+			h.ServeHTTP(w, r.WithContext(*ctx))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseDeadline(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	var deadlineTests = []struct {
+		val string
+		d   time.Duration
+		ok  bool
+	}{
+		{"", 0, false},
+		{"bad", 0, false},
+		{"1.5s", 1500 * time.Millisecond, true},
+		{"0s", 0, true},
+		{"-1s", 0, true},
+		{"2015-06-01T12:00:10Z", 10 * time.Second, true},
+		{"2015-06-01T11:00:00Z", 0, true},
+	}
+
+	for _, test := range deadlineTests {
+		d, ok := parseDeadline(test.val, now)
+		assert.Equal(t, test.ok, ok, test.val)
+		assert.Equal(t, test.d, d, test.val)
+	}
+}
+
+func TestDeadlineFromHeader(t *testing.T) {
+	t.Parallel()
+
+	var (
+		deadline time.Time
+		ok       bool
+		err      error
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		deadline, ok = ctx.Deadline()
+		err = ctx.Err()
+	}, nil)
+	stack.Push(DeadlineFromHeader("X-Timeout", time.Minute))
+
+	send := func(val string) {
+		si := stack.Get()
+		defer stack.Release(si)
+
+		r, _ := http.NewRequest("GET", "/", nil)
+		if val != "" {
+			r.Header.Set("X-Timeout", val)
+		}
+		si.Handler.ServeHTTP(nil, r)
+	}
+
+	// No header means no deadline.
+	send("")
+	assert.False(t, ok)
+
+	// A valid header sets the deadline...
+	send("10s")
+	if assert.True(t, ok) {
+		assert.WithinDuration(t, time.Now().Add(10*time.Second), deadline, time.Second)
+	}
+
+	// ... but it's capped at the limit.
+	send("1h")
+	if assert.True(t, ok) {
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	}
+
+	// Deadlines that have already passed are applied too.
+	send("0s")
+	assert.True(t, ok)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	send("2015-06-01T12:00:00Z")
+	assert.True(t, ok)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestDeadlineFromHeaderNoLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		deadline time.Time
+		ok       bool
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		deadline, ok = ctx.Deadline()
+	}, nil)
+	stack.Push(DeadlineFromHeader("X-Timeout", 0))
+
+	si := stack.Get()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Timeout", "1h")
+	si.Handler.ServeHTTP(nil, r)
+	stack.Release(si)
+
+	// Without a limit, the client's timeout is used as it is.
+	if assert.True(t, ok) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Second)
+	}
+}
+
+func TestDeadlineFromHeaderRequestContext(t *testing.T) {
+	t.Parallel()
+
+	var cancelled bool
+	stack := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled = true
+		case <-time.After(time.Second):
+		}
+	}), nil)
+	stack.Push(DeadlineFromHeader("X-Timeout", time.Minute))
+
+	si := stack.Get()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Timeout", "10ms")
+	si.Handler.ServeHTTP(nil, r)
+	stack.Release(si)
+
+	assert.True(t, cancelled)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// FeatureFlags returns a middleware that evaluates a set of feature flags for
+// each request, by calling the given provider once per request.  The resulting
+// flags are stored in the context, from where they can be retrieved with
+// GetFeatureFlags or IsEnabled.
+func FeatureFlags(provider func(*http.Request) map[string]bool) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = context.WithValue(*ctx, featureFlagsKey, provider(r))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetFeatureFlags retrieves the feature flags from the given context, or nil
+// if the context does not contain any.
+func GetFeatureFlags(ctx context.Context) map[string]bool {
+	flags, _ := ctx.Value(featureFlagsKey).(map[string]bool)
+	return flags
+}
+
+// IsEnabled returns whether the named feature flag is enabled in the given
+// context.  Flags that are not present are considered to be disabled.
+func IsEnabled(ctx context.Context, name string) bool {
+	return GetFeatureFlags(ctx)[name]
+}
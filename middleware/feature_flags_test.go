@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls    int
+		enabled  bool
+		disabled bool
+		missing  bool
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		enabled = IsEnabled(ctx, "new-ui")
+		disabled = IsEnabled(ctx, "beta")
+		missing = IsEnabled(ctx, "missing")
+	}, nil)
+	stack.Push(FeatureFlags(func(r *http.Request) map[string]bool {
+		calls++
+		return map[string]bool{
+			"new-ui": true,
+			"beta":   false,
+		}
+	}))
+
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+
+	assert.Equal(t, 1, calls)
+	assert.True(t, enabled)
+	assert.False(t, disabled)
+	assert.False(t, missing)
+
+	// Contexts without flags have nothing enabled.
+	assert.Nil(t, GetFeatureFlags(context.Background()))
+	assert.False(t, IsEnabled(context.Background(), "new-ui"))
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// HardTimeout returns a middleware that enforces a hard limit of d on the
+// wall-clock time taken to serve each request, including the time taken by
+// any middleware that it wraps.
+//
+// When the limit expires, the request's context (both the wolf context and
+// r.Context()) is cancelled, so that the handler can stop what it's doing.
+// Unlike a soft timeout such as DeadlineFromHeader, which only cancels the
+// context, the connection's read and write deadlines are also set (using
+// http.ResponseController), so that a handler that ignores the cancellation
+// and keeps writing will fail, and the connection will be closed rather than
+// reused.  Writers that wrap the connection's writer (e.g. those created by
+// WrapWriter) are looked through using their Unwrap method.  A response that
+// was partially written when the limit expired is therefore truncated, rather
+// than being sent as if it were complete.  If the underlying connection
+// doesn't support deadlines, only the context is cancelled.
+//
+// Since the handler can't be interrupted, HardTimeout still waits for it to
+// return; handlers should watch the context to return promptly.
+func HardTimeout(d time.Duration) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var cancel context.CancelFunc
+			*ctx, cancel = context.WithCancel(*ctx)
+			defer cancel()
+
+			// Stopping the timer doesn't wait for it to finish running, so
+			// we also make sure that it can't touch the connection once the
+			// handler has returned, when it may be serving another request.
+			var (
+				mu   sync.Mutex
+				done bool
+			)
+			rc := http.NewResponseController(w)
+			timer := time.AfterFunc(d, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if done {
+					return
+				}
+
+				cancel()
+
+				now := time.Now()
+				rc.SetReadDeadline(now)
+				rc.SetWriteDeadline(now)
+			})
+			defer func() {
+				timer.Stop()
+				mu.Lock()
+				done = true
+				mu.Unlock()
+			}()
+
+			h.ServeHTTP(w, r.WithContext(*ctx))
+		})
+	}
+}
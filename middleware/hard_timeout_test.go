@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestHardTimeout(t *testing.T) {
+	t.Parallel()
+
+	cancelled := make(chan bool, 1)
+	h := Apply(HardTimeout(20*time.Millisecond), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			w.Write([]byte("fast"))
+			return
+		}
+
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-ctx.Done():
+			cancelled <- r.Context().Err() != nil
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+
+		// Writes after the limit fail, and the connection is closed.
+		for i := 0; i < 100; i++ {
+			w.Write([]byte("more data that should never be seen"))
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/fast")
+	if assert.NoError(t, err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "fast", string(body))
+	}
+
+	resp, err = http.Get(srv.URL + "/slow")
+	if assert.NoError(t, err) {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		// The response is truncated.
+		assert.Error(t, err)
+		assert.Equal(t, "partial", string(body))
+	}
+	assert.True(t, <-cancelled)
+}
+
+func TestHardTimeoutNoDeadlines(t *testing.T) {
+	t.Parallel()
+
+	// Writers without deadline support still get the context cancelled.
+	var cancelled bool
+	h := Apply(HardTimeout(10*time.Millisecond), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-ctx.Done():
+			cancelled = true
+		case <-time.After(time.Second):
+		}
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, cancelled)
+}
+
+// deadlineRecorder records whether its write deadline was set after the
+// handler had returned.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	returned atomic.Bool
+	late     atomic.Bool
+}
+
+func (w *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	if w.returned.Load() {
+		w.late.Store(true)
+	}
+	return nil
+}
+
+func TestHardTimeoutAfterReturn(t *testing.T) {
+	t.Parallel()
+
+	// The limit expires at about the same time as the handler returns; the
+	// deadline must never be set once it has.
+	h := Apply(HardTimeout(time.Millisecond), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	})
+
+	var writers []*deadlineRecorder
+	for i := 0; i < 50; i++ {
+		w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(w, r)
+		w.returned.Store(true)
+		writers = append(writers, w)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	for _, w := range writers {
+		assert.False(t, w.late.Load())
+	}
+}
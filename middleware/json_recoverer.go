@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// JSONRecovererConfig configures the behaviour of a JSON recoverer.
+type JSONRecovererConfig struct {
+	// RecovererConfig controls the stack trace that is captured, and where
+	// it is reported, as for RecovererWithConfig.
+	RecovererConfig
+
+	// Development, if true, includes the recovered panic value in the
+	// default response body.  This should not be enabled in production,
+	// since panic values may contain sensitive information.
+	Development bool
+
+	// Render, if non-nil, is called with the recovered panic value and
+	// returns the value that is encoded as the response body.  The default
+	// renders {"error": "internal server error"}, along with a "panic" key
+	// containing the panic value in development mode.
+	Render func(recovered interface{}, development bool) interface{}
+}
+
+func defaultJSONRender(recovered interface{}, development bool) interface{} {
+	body := map[string]string{
+		"error": "internal server error",
+	}
+	if development {
+		body["panic"] = fmt.Sprint(recovered)
+	}
+	return body
+}
+
+// JSONRecoverer returns a middleware that is like Recoverer, but responds with
+// a JSON body.
+func JSONRecoverer() func(*context.Context, http.Handler) http.Handler {
+	return JSONRecovererWithConfig(JSONRecovererConfig{})
+}
+
+// JSONRecovererWithConfig is like JSONRecoverer, but with the given
+// configuration.
+func JSONRecovererWithConfig(config JSONRecovererConfig) func(*context.Context, http.Handler) http.Handler {
+	render := config.Render
+	if render == nil {
+		render = defaultJSONRender
+	}
+
+	return recoverer(config.RecovererConfig, func(w http.ResponseWriter, recovered interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(render(recovered, config.Development))
+	})
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/types"
+)
+
+func panickingStack(val interface{}, mw types.MiddlewareType) *MiddlewareStack {
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic(val)
+	}, nil)
+	stack.Push(mw)
+	return stack
+}
+
+func serveStack(stack *MiddlewareStack) *httptest.ResponseRecorder {
+	si := stack.Get()
+	defer stack.Release(si)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	si.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestJSONRecoverer(t *testing.T) {
+	t.Parallel()
+
+	w := serveStack(panickingStack("secret", JSONRecoverer()))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"error":"internal server error"}`+"\n", w.Body.String())
+
+	// Development mode includes the panic value.
+	w = serveStack(panickingStack("secret", JSONRecovererWithConfig(JSONRecovererConfig{
+		Development: true,
+	})))
+	assert.Equal(t, `{"error":"internal server error","panic":"secret"}`+"\n", w.Body.String())
+
+	// The body can be customized.
+	w = serveStack(panickingStack("secret", JSONRecovererWithConfig(JSONRecovererConfig{
+		Render: func(recovered interface{}, development bool) interface{} {
+			return []string{"oops"}
+		},
+	})))
+	assert.Equal(t, `["oops"]`+"\n", w.Body.String())
+
+	// Panics are reported in the same way as by the Recoverer.
+	var info PanicInfo
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic("secret")
+	}, []types.MiddlewareType{
+		RequestIDFunc(func() string { return "req-1" }),
+		JSONRecovererWithConfig(JSONRecovererConfig{
+			RecovererConfig: RecovererConfig{
+				StackSize: 64,
+				OnPanic:   func(i PanicInfo) { info = i },
+			},
+		}),
+	})
+	serveStack(stack)
+	assert.Equal(t, "secret", info.Recovered)
+	assert.Equal(t, "req-1", info.RequestID)
+	assert.Len(t, info.Stack, 64)
+}
+
+func TestJSONRecovererAbort(t *testing.T) {
+	t.Parallel()
+
+	stack := panickingStack(http.ErrAbortHandler, JSONRecoverer())
+	assert.Panics(t, func() {
+		serveStack(stack)
+	})
+}
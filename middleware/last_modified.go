@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// lastModifiedWriter sets the Last-Modified header on successful responses,
+// and replaces them with a 304 Not Modified if the client's copy is up to date.
+type lastModifiedWriter struct {
+	http.ResponseWriter
+	modified    string
+	notModified bool
+	wroteHeader bool
+
+	// Set once the response has been replaced with a 304, after which the
+	// handler's body is discarded.
+	discard bool
+}
+
+func (w *lastModifiedWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusOK {
+			w.Header().Set("Last-Modified", w.modified)
+			if w.notModified {
+				// As with http.ServeContent, drop the headers that describe
+				// the body that won't be sent.
+				h := w.Header()
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				h.Del("Content-Encoding")
+				w.discard = true
+				code = http.StatusNotModified
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *lastModifiedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, if the original http.ResponseWriter does.
+func (w *lastModifiedWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (w *lastModifiedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LastModified returns a middleware that handles conditional GET requests for
+// content with a known modification time, as returned by fn.  Successful (200
+// OK) responses are given a Last-Modified header, and if the request has an
+// If-Modified-Since header that is not older than the modification time, are
+// replaced with a 304 Not Modified, whose body is discarded.  The next handler
+// is always called, so that responses with any other status (e.g. a 404, or a
+// redirect) are passed through unchanged.
+//
+// Only GET and HEAD requests are handled; other requests, and requests for
+// which fn returns the zero time, are passed through unchanged.  As required
+// by RFC 7232, If-Modified-Since is ignored if the request also has an
+// If-None-Match header, which takes precedence.  Malformed If-Modified-Since
+// headers are also ignored.
+func LastModified(fn func(*http.Request) time.Time) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			modtime := fn(r)
+			if modtime.IsZero() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// HTTP dates only have a resolution of one second.
+			modtime = modtime.Truncate(time.Second)
+			lw := &lastModifiedWriter{
+				ResponseWriter: w,
+				modified:       modtime.UTC().Format(http.TimeFormat),
+			}
+
+			if r.Header.Get("If-None-Match") == "" {
+				ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+				lw.notModified = err == nil && !modtime.After(ims)
+			}
+
+			h.ServeHTTP(lw, r)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLastModified(t *testing.T) {
+	t.Parallel()
+
+	modtime := time.Date(2015, 6, 1, 12, 0, 0, 500, time.UTC)
+	formatted := "Mon, 01 Jun 2015 12:00:00 GMT"
+
+	var (
+		calls  int
+		status int
+	)
+	h := Apply(LastModified(func(r *http.Request) time.Time {
+		if r.URL.Path == "/unknown" {
+			return time.Time{}
+		}
+		return modtime
+	}), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		calls++
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+		w.Write([]byte("body"))
+	})
+
+	var inm string
+	send := func(method, path, ims string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		if ims != "" {
+			r.Header.Set("If-Modified-Since", ims)
+		}
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Unconditional requests get the header.
+	w := send("GET", "/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, formatted, w.Header().Get("Last-Modified"))
+	assert.Equal(t, "body", w.Body.String())
+
+	// Up-to-date clients get a 304, with the handler's body discarded.
+	calls = 0
+	w = send("GET", "/", formatted)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, formatted, w.Header().Get("Last-Modified"))
+	assert.Equal(t, "", w.Body.String())
+	w = send("HEAD", "/", "Tue, 02 Jun 2015 00:00:00 GMT")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 2, calls)
+
+	// If-None-Match takes precedence.
+	inm = `"abc"`
+	assert.Equal(t, http.StatusOK, send("GET", "/", formatted).Code)
+	inm = ""
+
+	// Outdated clients, malformed headers, and other methods pass through.
+	assert.Equal(t, http.StatusOK, send("GET", "/", "Sun, 31 May 2015 00:00:00 GMT").Code)
+	assert.Equal(t, http.StatusOK, send("GET", "/", "yesterday").Code)
+	w = send("POST", "/", formatted)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("Last-Modified"))
+
+	// Unknown modification times are ignored.
+	w = send("GET", "/unknown", formatted)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("Last-Modified"))
+
+	// Only successful responses get the header, or are replaced with a 304.
+	status = http.StatusNotFound
+	w = send("GET", "/", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "", w.Header().Get("Last-Modified"))
+	w = send("GET", "/", formatted)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "body", w.Body.String())
+}
+
+func TestLastModifiedFlush(t *testing.T) {
+	t.Parallel()
+
+	modtime := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := Apply(LastModified(func(r *http.Request) time.Time {
+		return modtime
+	}), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+		w.(http.Flusher).Flush()
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "body", w.Body.String())
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/router"
+)
+
+// LocaleConfig configures the behaviour of the Locale middleware.
+type LocaleConfig struct {
+	// Supported is the list of locales (as language tags, e.g. "en-US") that
+	// the application can serve.
+	Supported []string
+
+	// Default is the locale used when none of the client's preferred
+	// languages are supported, or the request has no Accept-Language header.
+	// If empty, the first supported locale is used.
+	Default string
+}
+
+// parseAcceptLanguage parses an Accept-Language header into its language
+// ranges, ordered by decreasing quality.
+func parseAcceptLanguage(header string) []router.AcceptItem {
+	ranges := router.ParseAcceptHeader(header)
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Q > ranges[j].Q
+	})
+	return ranges
+}
+
+// rangeMatches returns whether a language range matches a locale, either
+// exactly or as a prefix of it (e.g. "en" matches "en-US"), ignoring case.
+func rangeMatches(tag, locale string) bool {
+	if strings.EqualFold(locale, tag) {
+		return true
+	}
+	return len(locale) > len(tag) && locale[len(tag)] == '-' && strings.EqualFold(locale[:len(tag)], tag)
+}
+
+// matchLocale returns the supported locale that best matches the given
+// Accept-Language header, or the empty string if there is none.  For each
+// language range, in order of preference, an exact match is tried first, then
+// a supported locale that the range is a prefix of (e.g. "en" matches
+// "en-US"), then the range with its subtags removed (e.g. "en-GB" matches
+// "en").  Tags are compared case-insensitively.
+//
+// Locales matched by a range with a quality of zero (e.g. "fr;q=0") are
+// refused by the client, and are never chosen, even by the "*" range.
+func matchLocale(header string, supported []string) string {
+	ranges := parseAcceptLanguage(header)
+
+	var allowed []string
+	for _, s := range supported {
+		refused := false
+		for _, lr := range ranges {
+			if lr.Q <= 0 && lr.Value != "*" && rangeMatches(lr.Value, s) {
+				refused = true
+				break
+			}
+		}
+		if !refused {
+			allowed = append(allowed, s)
+		}
+	}
+
+	for _, lr := range ranges {
+		if lr.Q <= 0 {
+			continue
+		}
+		if lr.Value == "*" {
+			if len(allowed) > 0 {
+				return allowed[0]
+			}
+			continue
+		}
+
+		for _, s := range allowed {
+			if strings.EqualFold(s, lr.Value) {
+				return s
+			}
+		}
+		for _, s := range allowed {
+			if rangeMatches(lr.Value, s) {
+				return s
+			}
+		}
+		for tag := lr.Value; strings.Contains(tag, "-"); {
+			tag = tag[:strings.LastIndex(tag, "-")]
+			for _, s := range allowed {
+				if strings.EqualFold(s, tag) {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Locale returns a middleware that chooses the locale to serve each request
+// in, from the given list of supported locales, based on the request's
+// Accept-Language header.  The chosen locale is stored in the context, from
+// where it can be retrieved with GetLocale.  If none of the client's preferred
+// languages are supported, the first supported locale is used.
+func Locale(supported []string) func(*context.Context, http.Handler) http.Handler {
+	return LocaleWithConfig(LocaleConfig{Supported: supported})
+}
+
+// LocaleWithConfig is like Locale, but with the given configuration.
+func LocaleWithConfig(config LocaleConfig) func(*context.Context, http.Handler) http.Handler {
+	def := config.Default
+	if def == "" && len(config.Supported) > 0 {
+		def = config.Supported[0]
+	}
+
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale := matchLocale(r.Header.Get("Accept-Language"), config.Supported)
+			if locale == "" {
+				locale = def
+			}
+
+			*ctx = context.WithValue(*ctx, localeKey, locale)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetLocale retrieves the locale chosen by the Locale middleware from the
+// given context, or the empty string if there is none.
+func GetLocale(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey).(string)
+	return locale
+}
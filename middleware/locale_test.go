@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMatchLocale(t *testing.T) {
+	t.Parallel()
+
+	supported := []string{"en-US", "fr", "de-DE", "pt-BR"}
+	var localeTests = []struct {
+		header string
+		locale string
+	}{
+		{"", ""},
+		{"fr", "fr"},
+		{"FR", "fr"},
+		{"en-us", "en-US"},
+		{"en", "en-US"},
+		{"fr-CA", "fr"},
+		{"ja, de;q=0.5, fr;q=0.7", "fr"},
+		{"de;q=0.9, pt-BR", "pt-BR"},
+		{"fr;q=0, de-DE;q=0.1", "de-DE"},
+		{"ja, *;q=0.1", "en-US"},
+		{"ja, zh", ""},
+		{"en-GB;q=0.8, fr-FR;q=0.9", "fr"},
+
+		// Refused locales are never chosen, even by a wildcard.
+		{"*, en;q=0", "fr"},
+		{"*;q=0.5, en-US;q=0, fr;q=0", "de-DE"},
+		{"en-US;q=0, en", ""},
+		{"*;q=0", ""},
+	}
+	for _, test := range localeTests {
+		assert.Equal(t, test.locale, matchLocale(test.header, supported), test.header)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	t.Parallel()
+
+	var locale string
+	final := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		locale = GetLocale(ctx)
+	}
+
+	send := func(h http.Handler, header string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Accept-Language", header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	h := Apply(Locale([]string{"en", "fr", "de"}), final)
+	send(h, "de-AT, fr;q=0.8")
+	assert.Equal(t, "de", locale)
+	send(h, "ja")
+	assert.Equal(t, "en", locale)
+	send(h, "")
+	assert.Equal(t, "en", locale)
+
+	h = Apply(LocaleWithConfig(LocaleConfig{
+		Supported: []string{"en", "fr"},
+		Default:   "fr",
+	}), final)
+	send(h, "ja")
+	assert.Equal(t, "fr", locale)
+	send(h, "ja, en;q=0.2")
+	assert.Equal(t, "en", locale)
+
+	assert.Equal(t, "", GetLocale(context.Background()))
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MaxBodyBytesConfig configures the behaviour of the MaxBodyBytes middleware.
+type MaxBodyBytesConfig struct {
+	// Limit is the maximum size of a request body, in bytes.
+	Limit int64
+
+	// RespondTooLarge, if true, causes a 413 Request Entity Too Large
+	// response to be sent in place of the handler's response, once the
+	// handler has tried to read more than Limit bytes.  See
+	// MaxBodyBytesWithConfig.
+	RespondTooLarge bool
+}
+
+// limitedBody is a request body that records whether reading it failed because
+// it was too large.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// tooLargeWriter replaces the response with a 413 Request Entity Too Large if
+// the request body was too large by the time the response is written.
+type tooLargeWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	wroteHeader bool
+	discard     bool
+}
+
+func (w *tooLargeWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.body.exceeded {
+		w.discard = true
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *tooLargeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, if the underlying writer does.  Flushing
+// commits the response, so a body that is found to be too large afterwards no
+// longer replaces it.
+func (w *tooLargeWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok && !w.discard {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (w *tooLargeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MaxBodyBytes returns a middleware that limits the size of request bodies to
+// n bytes, using http.MaxBytesReader.  Reading more than n bytes from the
+// request body will return an error, so handlers should check for errors when
+// reading the body rather than assuming it was read in full.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return MaxBodyBytesWithConfig(MaxBodyBytesConfig{Limit: n})
+}
+
+// MaxBodyBytesWithConfig is like MaxBodyBytes, but allows configuring the
+// middleware's behaviour.
+//
+// With RespondTooLarge set, handlers don't need to check for an oversized body
+// themselves.  Since the size of a body generally isn't known until it has been
+// read, this is done by watching the handler's reads: once a read has failed
+// because the body is too large, whatever response the handler then writes
+// (e.g. an error from decoding the truncated body) is discarded and replaced by
+// a 413 response, as is an empty response.  A handler that writes its response
+// before reading the whole body, or that never reads past the limit, is
+// unaffected.
+func MaxBodyBytesWithConfig(config MaxBodyBytesConfig) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, config.Limit)
+			if !config.RespondTooLarge {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			body := &limitedBody{ReadCloser: r.Body}
+			r.Body = body
+			tw := &tooLargeWriter{ResponseWriter: w, body: body}
+			h.ServeHTTP(tw, r)
+
+			if !tw.wroteHeader && body.exceeded {
+				tw.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
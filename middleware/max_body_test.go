@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+	t.Parallel()
+
+	var (
+		body []byte
+		err  error
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		body, err = ioutil.ReadAll(r.Body)
+	}, nil)
+	stack.Push(MaxBodyBytes(5))
+
+	send := func(s string) {
+		si := stack.Get()
+		defer stack.Release(si)
+
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(s))
+		si.Handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// Bodies under the limit are read in full...
+	send("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(body))
+
+	// ... but oversized bodies result in an error.
+	send("abcdefgh")
+	assert.Error(t, err)
+}
+
+func TestMaxBodyBytesRespondTooLarge(t *testing.T) {
+	t.Parallel()
+
+	var called bool
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		called = true
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		w.Write(body)
+	}, nil)
+	stack.Push(MaxBodyBytesWithConfig(MaxBodyBytesConfig{
+		Limit:           5,
+		RespondTooLarge: true,
+	}))
+
+	send := func(s string) *httptest.ResponseRecorder {
+		called = false
+		si := stack.Get()
+		defer stack.Release(si)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(s))
+		si.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("abc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abc", w.Body.String())
+
+	// The handler's own error response is replaced.
+	w = send(strings.Repeat("x", 1024))
+	assert.True(t, called)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusRequestEntityTooLarge)+"\n", w.Body.String())
+}
+
+func TestMaxBodyBytesFlush(t *testing.T) {
+	t.Parallel()
+
+	var unwrapped http.ResponseWriter
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		unwrapped = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap()
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+	}, nil)
+	stack.Push(MaxBodyBytesWithConfig(MaxBodyBytesConfig{
+		Limit:           5,
+		RespondTooLarge: true,
+	}))
+
+	si := stack.Get()
+	defer stack.Release(si)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("abc"))
+	si.Handler.ServeHTTP(w, r)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Equal(t, w, unwrapped)
+}
@@ -18,6 +18,12 @@ var (
 
 // canonicalMiddleware is the 'canonical' middleware type - we coerce all other
 // middlewares to this type.
+//
+// Every middleware in a stack is given a pointer to the same context, which is
+// also the context that is passed to the final handler.  This means that any
+// changes a middleware makes to the context before calling the next handler
+// are visible to everything after it, and that a middleware can observe the
+// final state of the context once the next handler has returned.
 type canonicalMiddleware func(ctx *context.Context, h http.Handler) http.Handler
 
 // FinalFunc is the type of the function that is called at the end of a
@@ -34,13 +40,26 @@ type MiddlewareStack struct {
 	// Cache of pre-built middleware stacks
 	cache *sync.Pool
 
-	// The final handler that we call after applying all middleware.
-	final FinalFunc
+	// The final handler that we call after applying all middleware.  Only one
+	// of these will be set.
+	final        FinalFunc
+	finalHandler http.Handler
 
 	// The base context for all middleware (i.e. this is passed to the first
 	// middleware).  By default, it is set to `context.Background()`.
 	BaseContext context.Context
 
+	// OnConstructError, if set, is called with the recovered value when a
+	// middleware constructor panics while a new stack is being built.  The
+	// stack is then replaced by one that responds to the request with a 500
+	// Internal Server Error, and isn't reused.  If nil, the panic is
+	// propagated.
+	//
+	// Note that a stack is built eagerly when the MiddlewareStack is created,
+	// before this field can be set; use NewWithConfig to also handle panics at
+	// that point.
+	OnConstructError func(recovered interface{})
+
 	// List of middleware functions
 	funcs []canonicalMiddleware
 	mu    sync.Mutex
@@ -61,37 +80,103 @@ type StackItem struct {
 
 	// So we know whether or not we can return to a given pool.
 	pool *sync.Pool
+
+	// Set if the stack could not be built (see OnConstructError), in which
+	// case it is never returned to a pool.
+	broken bool
 }
 
 // New creates and returns a new middleware stack with some initial set of
 // middleware.
+//
+// The middleware constructors (i.e. the functions that wrap a http.Handler)
+// are invoked once before this function returns, so that any panics from them
+// happen here rather than on the first request.  Since constructors will also
+// be called again whenever the cache needs a new stack, they should be free of
+// side effects.
 func New(handler FinalFunc, middleware []types.MiddlewareType) *MiddlewareStack {
 	m := &MiddlewareStack{
 		final:       handler,
 		BaseContext: context.Background(),
 	}
 
-	// Push all existing.  We can use the 'unlocked' version since we're the
-	// only thing that owns this stack right now.
-	for _, mw := range middleware {
-		m.push(mw)
+	m.init(middleware)
+	return m
+}
+
+// NewHandler is like New, but uses the given http.Handler as the end of the
+// middleware chain.  The handler is called directly by the last middleware,
+// rather than through an adapter, and so does not receive the context.
+func NewHandler(final http.Handler, middleware []types.MiddlewareType) *MiddlewareStack {
+	m := &MiddlewareStack{
+		finalHandler: final,
+		BaseContext:  context.Background(),
+	}
+
+	m.init(middleware)
+	return m
+}
+
+// Config configures a MiddlewareStack created with NewWithConfig or
+// NewHandlerWithConfig.
+type Config struct {
+	// OnConstructError is used as the stack's OnConstructError hook,
+	// including for the stack that is built when it is created.
+	OnConstructError func(recovered interface{})
+}
+
+// NewWithConfig is like New, but with the given configuration.
+func NewWithConfig(handler FinalFunc, middleware []types.MiddlewareType, config Config) *MiddlewareStack {
+	m := &MiddlewareStack{
+		final:            handler,
+		BaseContext:      context.Background(),
+		OnConstructError: config.OnConstructError,
 	}
 
-	m.resetPool()
+	m.init(middleware)
 	return m
 }
 
+// NewHandlerWithConfig is like NewHandler, but with the given configuration.
+func NewHandlerWithConfig(final http.Handler, middleware []types.MiddlewareType, config Config) *MiddlewareStack {
+	m := &MiddlewareStack{
+		finalHandler:     final,
+		BaseContext:      context.Background(),
+		OnConstructError: config.OnConstructError,
+	}
+
+	m.init(middleware)
+	return m
+}
+
+// Add the initial set of middleware to a newly-created stack, and set up the
+// cache.
+func (m *MiddlewareStack) init(middleware []types.MiddlewareType) {
+	orig := make([]types.MiddlewareType, 0, len(middleware))
+	funcs := make([]canonicalMiddleware, 0, len(middleware))
+	for _, mw := range middleware {
+		orig = append(orig, mw)
+		funcs = append(funcs, makeCanonical(mw))
+	}
+
+	m.resetPool(orig, funcs)
+}
+
 // Push adds a new middleware to the current stack.  This invalidates any
 // existing cached stacks.
 func (m *MiddlewareStack) Push(mw types.MiddlewareType) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Call 'real' push function
-	m.push(mw)
+	// We store both the original and canonical functions, so we can remove a
+	// middleware.  The new lists never share backing arrays with the current
+	// ones, so that nothing changes if building the new stack panics.
+	n := len(m.orig)
+	orig := append(m.orig[:n:n], mw)
+	funcs := append(m.funcs[:n:n], makeCanonical(mw))
 
 	// Invalidate any existing cache
-	m.resetPool()
+	m.resetPool(orig, funcs)
 }
 
 // Convert a middleware into our canonical type.  Panics on error.
@@ -115,15 +200,6 @@ func makeCanonical(mw types.MiddlewareType) canonicalMiddleware {
 	return resolvedFn
 }
 
-// Add a new middleware to the current stack, without locking or resetting the
-// cache.  Panics on error.
-func (m *MiddlewareStack) push(mw types.MiddlewareType) {
-	// We store both the original and canonical functions, so we can remove a
-	// middleware
-	m.orig = append(m.orig, mw)
-	m.funcs = append(m.funcs, makeCanonical(mw))
-}
-
 // Remove a middleware from the stack.  If the middleware does not exist in
 // this stack, this function will return ErrMiddlewareNotFound.  If the
 // middleware was removed, this function will invalidate any existing cached
@@ -146,20 +222,35 @@ func (m *MiddlewareStack) Remove(mw types.MiddlewareType) error {
 		return ErrMiddlewareNotFound
 	}
 
-	// Remove from the array
-	m.orig = append(m.orig[:idx], m.orig[idx+1:]...)
-	m.funcs = append(m.funcs[:idx], m.funcs[idx+1:]...)
+	// Remove from (copies of) the arrays
+	orig := append(append([]types.MiddlewareType{}, m.orig[:idx]...), m.orig[idx+1:]...)
+	funcs := append(append([]canonicalMiddleware{}, m.funcs[:idx]...), m.funcs[idx+1:]...)
 
 	// Invalidate the middleware cache, since we've changed things
-	m.resetPool()
+	m.resetPool(orig, funcs)
 	return nil
 }
 
-// Reset (invalidate) any cached stacks.
-func (m *MiddlewareStack) resetPool() {
-	// Create an entirely new pool (the old one gets garbage-collected)
+// Reset (invalidate) any cached stacks, and switch to the given list of
+// middleware.  If a middleware constructor panics, the stack is left as it
+// was.
+func (m *MiddlewareStack) resetPool(orig []types.MiddlewareType, funcs []canonicalMiddleware) {
+	// Eagerly build a stack, so that a panicking middleware constructor is
+	// noticed when the middleware is added, instead of lazily on some later
+	// request.
+	stack := m.newResolved(funcs)
+
+	// Create an entirely new pool (the old one gets garbage-collected), and
+	// seed it with the stack we just built.
+	m.orig = orig
+	m.funcs = funcs
 	m.cache = &sync.Pool{
-		New: m.newResolved,
+		New: func() interface{} {
+			return m.newResolved(funcs)
+		},
+	}
+	if !stack.broken {
+		m.cache.Put(stack)
 	}
 }
 
@@ -168,6 +259,19 @@ func (m *MiddlewareStack) Get() *StackItem {
 	c := m.cache
 	stack := c.Get().(*StackItem)
 	stack.pool = c
+
+	// Stacks are built before they're needed (including the one built by
+	// New), so BaseContext may have changed since.
+	stack.Context = m.BaseContext
+	return stack
+}
+
+// GetWithContext is like Get, but the returned stack will use the given
+// context, instead of BaseContext, as the context passed to the first
+// middleware.
+func (m *MiddlewareStack) GetWithContext(ctx context.Context) *StackItem {
+	stack := m.Get()
+	stack.Context = ctx
 	return stack
 }
 
@@ -175,7 +279,7 @@ func (m *MiddlewareStack) Get() *StackItem {
 func (m *MiddlewareStack) Release(s *StackItem) {
 	// Reset the context in the stack.
 	s.Context = m.BaseContext
-	if s.pool != m.cache {
+	if s.pool != m.cache || s.broken {
 		return
 	}
 
@@ -187,21 +291,40 @@ func (m *MiddlewareStack) Release(s *StackItem) {
 // cache does not have any available values.
 //
 // This is where the actual middlewares are applied.
-func (m *MiddlewareStack) newResolved() interface{} {
+func (m *MiddlewareStack) newResolved(funcs []canonicalMiddleware) (ret *StackItem) {
+	defer func() {
+		if m.OnConstructError == nil {
+			return
+		}
+		if err := recover(); err != nil {
+			m.OnConstructError(err)
+			ret = &StackItem{
+				Context: m.BaseContext,
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}),
+				broken: true,
+			}
+		}
+	}()
+
 	stack := &StackItem{
 		Context: m.BaseContext,
-		pool:    m.cache,
 	}
-	final := m.final
-
-	stack.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Dispatch to our final handler.
-		final(stack.Context, w, r)
-	})
+	if m.finalHandler != nil {
+		stack.Handler = m.finalHandler
+	} else {
+		final := m.final
+
+		stack.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Dispatch to our final handler.
+			final(stack.Context, w, r)
+		})
+	}
 
 	// Apply all middleware.
-	for i := len(m.funcs) - 1; i >= 0; i-- {
-		stack.Handler = m.funcs[i](&stack.Context, stack.Handler)
+	for i := len(funcs) - 1; i >= 0; i-- {
+		stack.Handler = funcs[i](&stack.Context, stack.Handler)
 	}
 
 	return stack
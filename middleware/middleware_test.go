@@ -8,6 +8,8 @@ import (
 	//"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/types"
 )
 
 func TestMiddlewareTypes(t *testing.T) {
@@ -104,6 +106,206 @@ func TestRemove(t *testing.T) {
 	stack.Release(si)
 }
 
+func TestNewHandler(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "final")
+	})
+	stack := NewHandler(final, []types.MiddlewareType{
+		func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, "one")
+				h.ServeHTTP(w, r)
+			})
+		},
+	})
+
+	si := stack.Get()
+	defer stack.Release(si)
+
+	sendRequest(si.Handler)
+	assert.Equal(t, []string{"one", "final"}, calls)
+}
+
+func TestGetWithContext(t *testing.T) {
+	t.Parallel()
+
+	var seen interface{}
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		seen = ctx.Value("key")
+	}, nil)
+
+	si := stack.GetWithContext(context.WithValue(context.Background(), "key", "value"))
+	sendRequest(si.Handler)
+	stack.Release(si)
+	assert.Equal(t, "value", seen)
+
+	// Released stacks go back to using the base context.
+	si = stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+	assert.Nil(t, seen)
+}
+
+func TestBaseContext(t *testing.T) {
+	t.Parallel()
+
+	var seen interface{}
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		seen = ctx.Value("key")
+	}, nil)
+
+	// Setting the base context after New applies to the very first request,
+	// even though a stack has already been built.
+	stack.BaseContext = context.WithValue(context.Background(), "key", "value")
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+	assert.Equal(t, "value", seen)
+}
+
+func TestEagerConstruction(t *testing.T) {
+	t.Parallel()
+
+	final, _ := makeFinalFunc()
+	bad := func(h http.Handler) http.Handler {
+		panic("bad constructor")
+	}
+
+	// Constructor panics should happen when the middleware is added.
+	assert.Panics(t, func() {
+		New(final, []types.MiddlewareType{bad})
+	})
+
+	stack := New(final, nil)
+	assert.Panics(t, func() {
+		stack.Push(bad)
+	})
+}
+
+func TestEagerConstructionRollback(t *testing.T) {
+	t.Parallel()
+
+	final, called := makeFinalFunc()
+	bad := func(h http.Handler) http.Handler {
+		panic("bad constructor")
+	}
+
+	var ran bool
+	good := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ran = true
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	stack := New(final, []types.MiddlewareType{good})
+
+	// A recovered constructor panic must leave the stack unchanged.
+	assert.Panics(t, func() {
+		stack.Push(bad)
+	})
+	assert.Equal(t, ErrMiddlewareNotFound, stack.Remove(bad))
+
+	si := stack.Get()
+	assert.NoError(t, sendRequest(si.Handler))
+	assert.True(t, ran)
+	assert.True(t, *called)
+	stack.Release(si)
+
+	// ... and further changes still work.
+	assert.NotPanics(t, func() {
+		stack.Push(func(h http.Handler) http.Handler { return h })
+	})
+	assert.NoError(t, stack.Remove(good))
+}
+
+func TestOnConstructError(t *testing.T) {
+	t.Parallel()
+
+	final, _ := makeFinalFunc()
+	noop := func(h http.Handler) http.Handler { return h }
+
+	// A constructor that starts failing after the stack has been created.
+	fail := false
+	flaky := func(h http.Handler) http.Handler {
+		if fail {
+			panic("flaky constructor")
+		}
+		return h
+	}
+
+	stack := New(final, []types.MiddlewareType{flaky})
+	var recovered []interface{}
+	stack.OnConstructError = func(r interface{}) {
+		recovered = append(recovered, r)
+	}
+
+	// Rebuilding the stack calls the hook rather than panicking...
+	fail = true
+	assert.NotPanics(t, func() {
+		stack.Push(noop)
+	})
+
+	// ... as does building a stack for a request, which is served with a 500.
+	var item *StackItem
+	assert.NotPanics(t, func() {
+		item = stack.Get()
+	})
+	assert.Equal(t, []interface{}{"flaky constructor", "flaky constructor"}, recovered)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	item.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	stack.Release(item)
+
+	// Without a hook, the panic is propagated.
+	stack.OnConstructError = nil
+	assert.Panics(t, func() {
+		stack.Get()
+	})
+}
+
+func TestNewWithConfig(t *testing.T) {
+	t.Parallel()
+
+	bad := func(h http.Handler) http.Handler {
+		panic("bad constructor")
+	}
+
+	var recovered []interface{}
+	config := Config{
+		OnConstructError: func(r interface{}) {
+			recovered = append(recovered, r)
+		},
+	}
+
+	// The hook also covers the stack built on creation.
+	final, called := makeFinalFunc()
+	var stack *MiddlewareStack
+	assert.NotPanics(t, func() {
+		stack = NewWithConfig(final, []types.MiddlewareType{bad}, config)
+	})
+	assert.Equal(t, []interface{}{"bad constructor"}, recovered)
+
+	item := stack.Get()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	item.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.False(t, *called)
+	stack.Release(item)
+
+	recovered = nil
+	assert.NotPanics(t, func() {
+		NewHandlerWithConfig(http.NotFoundHandler(), []types.MiddlewareType{bad}, config)
+	})
+	assert.Equal(t, []interface{}{"bad constructor"}, recovered)
+}
+
 func sendRequest(h http.Handler) error {
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest("GET", "/", nil)
@@ -0,0 +1,35 @@
+// Package middlewaretest provides utilities for testing middleware.
+package middlewaretest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/middleware"
+	"github.com/andrew-d/wolf/types"
+)
+
+// RunWithContext serves the given request with the given middleware, wrapping
+// a final handler that writes nothing, and returns the context that the final
+// handler was called with, along with the recorded response.  This makes it
+// easy to check which values a middleware adds to the context:
+//
+//	ctx, w := middlewaretest.RunWithContext(middleware.RequestID(), r)
+//	id := middleware.GetRequestID(ctx)
+//
+// The middleware is applied with middleware.Apply, and so is given a fresh
+// context.Background() for every call, without any of the caching done by a
+// MiddlewareStack.  If the middleware doesn't call the next handler (e.g.
+// because it rejects the request), the returned context is nil.
+func RunWithContext(mw types.MiddlewareType, r *http.Request) (context.Context, *httptest.ResponseRecorder) {
+	var final context.Context
+	h := middleware.Apply(mw, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		final = ctx
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return final, w
+}
@@ -0,0 +1,33 @@
+package middlewaretest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/andrew-d/wolf/middleware"
+)
+
+func TestRunWithContext(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	ctx, w := RunWithContext(middleware.RequestIDFunc(func() string {
+		return "abc123"
+	}), r)
+	if assert.NotNil(t, ctx) {
+		assert.Equal(t, "abc123", middleware.GetRequestID(ctx))
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Middleware that doesn't call the next handler gives no context.
+	reject := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+	ctx, w = RunWithContext(reject, r)
+	assert.Nil(t, ctx)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// OnStatus returns a middleware that calls fn after the downstream handler has
+// run, if the response's status code satisfies match.  For example, it can be
+// used to log or decorate error responses:
+//
+//	OnStatus(func(status int) bool { return status >= 400 }, logError)
+//
+// The status is determined by wrapping the ResponseWriter with WrapWriter
+// (unless it is already a WrapResponseWriter).  If the handler didn't write
+// anything, the status is considered to be 200 OK, as with net/http.
+func OnStatus(match func(int) bool, fn func(context.Context, http.ResponseWriter, *http.Request, int)) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww, ok := w.(WrapResponseWriter)
+			if !ok {
+				ww = WrapWriter(w)
+			}
+
+			h.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if match(status) {
+				fn(*ctx, ww, r, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestOnStatus(t *testing.T) {
+	t.Parallel()
+
+	var statuses []int
+	mw := OnStatus(func(status int) bool {
+		return status >= 500
+	}, func(ctx context.Context, w http.ResponseWriter, r *http.Request, status int) {
+		statuses = append(statuses, status)
+	})
+
+	send := func(status int) {
+		stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if status != 0 {
+				w.WriteHeader(status)
+			}
+		}, nil)
+		stack.Push(mw)
+
+		si := stack.Get()
+		defer stack.Release(si)
+
+		r, _ := http.NewRequest("GET", "/", nil)
+		si.Handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	send(http.StatusOK)
+	send(0)
+	send(http.StatusNotFound)
+	assert.Len(t, statuses, 0)
+
+	send(http.StatusInternalServerError)
+	assert.Equal(t, []int{http.StatusInternalServerError}, statuses)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// Provide returns a middleware that calls factory once for each request, and
+// stores the resulting value in the context under the given key.  This allows
+// injecting per-request dependencies (e.g. a database transaction) into
+// handlers, which can retrieve them with ctx.Value or router.Value:
+//
+//	b.Use(middleware.Provide(txKey, func(r *http.Request) (interface{}, error) {
+//		return db.Begin()
+//	}))
+//
+// If the factory returns an error, the request is not passed on, and the
+// client is sent a 500 Internal Server Error instead.  As with
+// context.WithValue, the key should be of an unexported type, to avoid
+// collisions with other packages.
+func Provide(key interface{}, factory func(*http.Request) (interface{}, error)) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			val, err := factory(r)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			*ctx = context.WithValue(*ctx, key, val)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
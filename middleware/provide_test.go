@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type provideKey int
+
+func TestProvide(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls int
+		fail  bool
+		got   interface{}
+	)
+	h := Apply(Provide(provideKey(0), func(r *http.Request) (interface{}, error) {
+		if fail {
+			return nil, errors.New("no connection")
+		}
+		calls++
+		return calls, nil
+	}), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		got = ctx.Value(provideKey(0))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	// The factory is called once per request.
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 1, got)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 2, got)
+
+	// Errors prevent the handler from running.
+	got = nil
+	fail = true
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// RealIPConfig configures the behaviour of the RealIP middleware.
+type RealIPConfig struct {
+	// TrustedProxies is the list of networks whose forwarding headers are
+	// trusted.  Headers from peers outside of these networks are ignored.
+	TrustedProxies []net.IPNet
+
+	// RewriteRemoteAddr, if true, causes the request passed to the next
+	// handler to have its RemoteAddr replaced with the client's IP address.
+	RewriteRemoteAddr bool
+}
+
+// RealIP returns a middleware that determines the IP address of the client
+// that made each request, and stores it in the context, from where it can be
+// retrieved with GetClientIP.
+//
+// If the immediate peer is in one of the trusted networks, the client's IP is
+// taken from the X-Forwarded-For header (skipping over any other trusted
+// proxies that appended to it), or otherwise from the X-Real-IP header.  A
+// malformed entry in X-Forwarded-For stops the search, and the last trusted
+// proxy before it is taken to be the client.  The forwarding headers of
+// untrusted peers are ignored, since any client could set them to claim to be
+// someone else; in that case, the peer's own IP is used.
+func RealIP(trustedProxies []net.IPNet) func(*context.Context, http.Handler) http.Handler {
+	return RealIPWithConfig(RealIPConfig{TrustedProxies: trustedProxies})
+}
+
+// RealIPWithConfig is like RealIP, but with the given configuration.
+func RealIPWithConfig(config RealIPConfig) func(*context.Context, http.Handler) http.Handler {
+	trusted := func(ip net.IP) bool {
+		for _, n := range config.TrustedProxies {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trusted)
+			*ctx = context.WithValue(*ctx, clientIPKey, ip)
+
+			if config.RewriteRemoteAddr && ip != nil {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.RemoteAddr = ip.String()
+				r = r2
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP determines the IP address of the client that made a request.
+func clientIP(r *http.Request, trusted func(net.IP) bool) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !trusted(peer) {
+		return peer
+	}
+
+	// Each proxy appends the address it received the request from, so we
+	// walk backwards until we find an address that we don't trust.  A proxy
+	// may add its own header line rather than appending to an existing one,
+	// so every line is considered, in order.
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		ip := peer
+		addrs := strings.Split(strings.Join(values, ","), ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := net.ParseIP(strings.TrimSpace(addrs[i]))
+			if addr == nil {
+				// Can't go any further back than a malformed entry, so
+				// the client is the last trusted proxy we reached.
+				break
+			}
+			ip = addr
+			if !trusted(ip) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	return peer
+}
+
+// GetClientIP retrieves the client's IP address, as determined by RealIP,
+// from the given context.  It returns nil if the middleware hasn't been run,
+// or if the IP address could not be determined.
+func GetClientIP(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(clientIPKey).(net.IP)
+	return ip
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []net.IPNet{*proxies}
+
+	var realIPTests = []struct {
+		remote string
+		xff    string
+		realIP string
+		ip     string
+	}{
+		// Untrusted peers can't spoof their address.
+		{"1.2.3.4:1234", "5.6.7.8", "", "1.2.3.4"},
+		{"1.2.3.4:1234", "", "5.6.7.8", "1.2.3.4"},
+
+		// Trusted proxies are skipped over.
+		{"10.0.0.1:1234", "5.6.7.8", "", "5.6.7.8"},
+		{"10.0.0.1:1234", "9.9.9.9, 5.6.7.8, 10.0.0.2", "", "5.6.7.8"},
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"10.0.0.1:1234", "", "5.6.7.8", "5.6.7.8"},
+
+		// Malformed entries stop the search, without falling back to
+		// X-Real-IP.
+		{"10.0.0.1:1234", "5.6.7.8, bogus, 10.0.0.2", "9.9.9.9", "10.0.0.2"},
+		{"10.0.0.1:1234", "bogus", "9.9.9.9", "10.0.0.1"},
+
+		// Without headers, the peer is the client.
+		{"10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"[::1]:1234", "", "", "::1"},
+		{"garbage", "", "", ""},
+	}
+
+	for _, test := range realIPTests {
+		var ip, remote string
+		h := Apply(RealIPWithConfig(RealIPConfig{
+			TrustedProxies:    trusted,
+			RewriteRemoteAddr: true,
+		}), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if cip := GetClientIP(ctx); cip != nil {
+				ip = cip.String()
+			}
+			remote = r.RemoteAddr
+		})
+
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		if test.realIP != "" {
+			r.Header.Set("X-Real-IP", test.realIP)
+		}
+		h.ServeHTTP(nil, r)
+
+		assert.Equal(t, test.ip, ip, "%+v", test)
+		if test.ip != "" {
+			assert.Equal(t, test.ip, remote, "%+v", test)
+		}
+	}
+
+	// Every X-Forwarded-For line is considered, so a line sent by the client
+	// can't take precedence over the one added by the proxy.
+	var ip net.IP
+	h := Apply(RealIP(trusted), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		ip = GetClientIP(ctx)
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Add("X-Forwarded-For", "6.6.6.6")
+	r.Header.Add("X-Forwarded-For", "5.5.5.5")
+	h.ServeHTTP(nil, r)
+	assert.Equal(t, "5.5.5.5", ip.String())
+
+	// RemoteAddr is only rewritten when configured.
+	var remote string
+	h = Apply(RealIP(trusted), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	})
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8")
+	h.ServeHTTP(nil, r)
+	assert.Equal(t, "10.0.0.1:1234", remote)
+
+	assert.Nil(t, GetClientIP(context.Background()))
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+
+	"golang.org/x/net/context"
+)
+
+// defaultStackSize is the number of bytes of stack trace captured by the
+// Recoverer when RecovererConfig.StackSize is zero.
+const defaultStackSize = 8 << 10
+
+// PanicInfo describes a panic recovered by the Recoverer.
+type PanicInfo struct {
+	// The value passed to panic.
+	Recovered interface{}
+
+	// The method and path of the request that was being served.
+	Method string
+	Path   string
+
+	// The request's ID (see RequestID), or the empty string if it doesn't
+	// have one.
+	RequestID string
+
+	// The stack trace of the panicking goroutine (or of all goroutines, if
+	// RecovererConfig.StackAll is set), as formatted by runtime.Stack, and
+	// truncated to RecovererConfig.StackSize bytes.
+	Stack []byte
+}
+
+// RecovererConfig configures the behaviour of the Recoverer middleware.
+type RecovererConfig struct {
+	// StackSize is the maximum number of bytes of stack trace to capture.
+	// If zero, 8KB is captured; if negative, no stack trace is captured.
+	StackSize int
+
+	// StackAll, if true, captures the stacks of all goroutines, rather than
+	// only the one that panicked.  This can be expensive, since the world
+	// is stopped while the stacks are collected.
+	StackAll bool
+
+	// OnPanic, if non-nil, is called with the details of each recovered
+	// panic, e.g. to pass them to a structured logger or an error-tracking
+	// service.  If nil, the panic and stack trace are logged with the log
+	// package.
+	OnPanic func(PanicInfo)
+}
+
+func defaultOnPanic(info PanicInfo) {
+	log.Printf("middleware: recovered from panic serving %s %s (request %q): %v\n%s",
+		info.Method, info.Path, info.RequestID, info.Recovered, info.Stack)
+}
+
+// Recoverer returns a middleware that recovers from panics in downstream
+// handlers, logs them along with a stack trace, and responds with a 500
+// Internal Server Error.  Panics with the value http.ErrAbortHandler are
+// re-panicked, so that they can abort the request as intended.
+func Recoverer() func(*context.Context, http.Handler) http.Handler {
+	return RecovererWithConfig(RecovererConfig{})
+}
+
+// RecovererWithConfig is like Recoverer, but with the given configuration.
+func RecovererWithConfig(config RecovererConfig) func(*context.Context, http.Handler) http.Handler {
+	return recoverer(config, func(w http.ResponseWriter, recovered interface{}) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	})
+}
+
+// recoverer returns a middleware that recovers from panics as configured,
+// and then calls respond to write the response.  It is shared by the
+// Recoverer and JSONRecoverer.
+func recoverer(config RecovererConfig, respond func(w http.ResponseWriter, recovered interface{})) func(*context.Context, http.Handler) http.Handler {
+	size := config.StackSize
+	if size == 0 {
+		size = defaultStackSize
+	}
+	onPanic := config.OnPanic
+	if onPanic == nil {
+		onPanic = defaultOnPanic
+	}
+
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				var stack []byte
+				if size > 0 {
+					stack = make([]byte, size)
+					stack = stack[:runtime.Stack(stack, config.StackAll)]
+				}
+
+				onPanic(PanicInfo{
+					Recovered: err,
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					RequestID: GetRequestID(*ctx),
+					Stack:     stack,
+				})
+
+				respond(w, err)
+			}()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/types"
+)
+
+func TestRecoverer(t *testing.T) {
+	t.Parallel()
+
+	final := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}
+
+	var info PanicInfo
+	stack := New(final, []types.MiddlewareType{
+		RequestIDFunc(func() string { return "req-1" }),
+		RecovererWithConfig(RecovererConfig{
+			OnPanic: func(i PanicInfo) { info = i },
+		}),
+	})
+
+	w := serveStack(stack)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "boom", info.Recovered)
+	assert.Equal(t, "GET", info.Method)
+	assert.Equal(t, "/", info.Path)
+	assert.Equal(t, "req-1", info.RequestID)
+	assert.True(t, len(info.Stack) > 0)
+	assert.True(t, len(info.Stack) <= defaultStackSize)
+	assert.Contains(t, string(info.Stack), "TestRecoverer")
+
+	// The size of the trace can be limited, or disabled entirely.
+	stack = New(final, []types.MiddlewareType{
+		RecovererWithConfig(RecovererConfig{
+			StackSize: 64,
+			OnPanic:   func(i PanicInfo) { info = i },
+		}),
+	})
+	serveStack(stack)
+	assert.Len(t, info.Stack, 64)
+	assert.Equal(t, "", info.RequestID)
+
+	stack = New(final, []types.MiddlewareType{
+		RecovererWithConfig(RecovererConfig{
+			StackSize: -1,
+			OnPanic:   func(i PanicInfo) { info = i },
+		}),
+	})
+	serveStack(stack)
+	assert.Nil(t, info.Stack)
+
+	// All goroutines can be included.
+	stack = New(final, []types.MiddlewareType{
+		RecovererWithConfig(RecovererConfig{
+			StackSize: 1 << 20,
+			StackAll:  true,
+			OnPanic:   func(i PanicInfo) { info = i },
+		}),
+	})
+	serveStack(stack)
+	assert.True(t, strings.Count(string(info.Stack), "goroutine ") > 1)
+}
+
+func TestRecovererAbort(t *testing.T) {
+	t.Parallel()
+
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}, []types.MiddlewareType{Recoverer()})
+	assert.Panics(t, func() {
+		serveStack(stack)
+	})
+}
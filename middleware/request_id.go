@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// RequestIDHeader is the header that request IDs are read from and written to.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID supplied by the
+// client.
+const maxRequestIDLength = 128
+
+var (
+	reqPrefix string
+	reqID     uint64
+)
+
+func init() {
+	var buf [12]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString(buf[:])
+	reqPrefix = strings.NewReplacer("+", "", "/", "").Replace(b64)
+}
+
+// defaultRequestID generates an ID that is unique to this process, made up of
+// a random prefix and an increasing counter.
+func defaultRequestID() string {
+	id := atomic.AddUint64(&reqID, 1)
+	return fmt.Sprintf("%s-%06d", reqPrefix, id)
+}
+
+// validRequestID returns whether an ID supplied by the client is safe to use:
+// it must be no longer than maxRequestIDLength, and made up of printable ASCII
+// characters other than spaces, so that it can't break up log lines or inject
+// anything into response headers.
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns a middleware that assigns a unique ID to each request.  If
+// the request already has a valid X-Request-ID header (at most 128 printable
+// ASCII characters, without spaces), its value is used as the ID; otherwise, a
+// new ID is generated.  The ID is stored in the context, from
+// where it can be retrieved with GetRequestID, and is echoed back to the
+// client in the X-Request-ID response header.
+func RequestID() func(*context.Context, http.Handler) http.Handler {
+	return RequestIDFunc(defaultRequestID)
+}
+
+// RequestIDFunc is like RequestID, except that new IDs are generated with the
+// given function, which allows using IDs from some other system (e.g. trace
+// IDs).  The function must be safe to call from multiple goroutines.
+func RequestIDFunc(gen func() string) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || !validRequestID(id) {
+				id = gen()
+			}
+
+			*ctx = context.WithValue(*ctx, requestIDKey, id)
+			w.Header().Set(RequestIDHeader, id)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetRequestID retrieves the request ID from the given context, or the empty
+// string if the context does not contain one.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	var seen string
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(ctx)
+	}, nil)
+	stack.Push(RequestIDFunc(func() string { return "generated" }))
+
+	// A new ID is generated if there isn't one...
+	si := stack.Get()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	si.Handler.ServeHTTP(w, r)
+	stack.Release(si)
+
+	assert.Equal(t, "generated", seen)
+	assert.Equal(t, "generated", w.Header().Get(RequestIDHeader))
+
+	// ... and an incoming ID is reused.
+	si = stack.Get()
+	w = httptest.NewRecorder()
+	r.Header.Set(RequestIDHeader, "incoming")
+	si.Handler.ServeHTTP(w, r)
+	stack.Release(si)
+
+	assert.Equal(t, "incoming", seen)
+	assert.Equal(t, "incoming", w.Header().Get(RequestIDHeader))
+
+	// Invalid incoming IDs are replaced.
+	si = stack.Get()
+	w = httptest.NewRecorder()
+	r.Header.Set(RequestIDHeader, strings.Repeat("x", 1000))
+	si.Handler.ServeHTTP(w, r)
+	stack.Release(si)
+
+	assert.Equal(t, "generated", seen)
+	assert.Equal(t, "generated", w.Header().Get(RequestIDHeader))
+}
+
+func TestValidRequestID(t *testing.T) {
+	t.Parallel()
+
+	var validTests = []struct {
+		id    string
+		valid bool
+	}{
+		{"abc-123", true},
+		{"7f3c2a1e-9b8d-4e5f-a6b7-c8d9e0f1a2b3", true},
+		{strings.Repeat("x", 128), true},
+		{strings.Repeat("x", 129), false},
+		{"has space", false},
+		{"tab\there", false},
+		{"new\nline", false},
+		{"del\x7f", false},
+		{"caf\u00e9", false},
+	}
+
+	for _, test := range validTests {
+		assert.Equal(t, test.valid, validRequestID(test.id), test.id)
+	}
+}
+
+func TestDefaultRequestID(t *testing.T) {
+	t.Parallel()
+
+	a, b := defaultRequestID(), defaultRequestID()
+	assert.NotEmpty(t, a)
+	assert.NotEqual(t, a, b)
+}
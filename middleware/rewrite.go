@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// RewriteRule describes a single rewrite of a request path.  Any part of the
+// path that matches Pattern is replaced with Replacement, which may refer to
+// capture groups in the pattern as described by regexp.Regexp.Expand (e.g. "$1"
+// or "${name}").
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Rewrite returns a middleware that rewrites the path of each request using the
+// given rules, which are applied in order (so each rule sees the result of the
+// previous ones).  For example, the following rule maps "/v1/users" to
+// "/users":
+//
+//	RewriteRule{regexp.MustCompile(`^/v1(/.*)$`), "$1"}
+//
+// When used as global middleware on a router, this runs before the router
+// matches the request, so the rewritten path is used for routing.  Since the
+// rules operate on the unescaped path, the request's RawPath is cleared when
+// the path changes, so that the two remain consistent.  The original request is
+// not modified.
+func Rewrite(rules []RewriteRule) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			for _, rule := range rules {
+				path = rule.Pattern.ReplaceAllString(path, rule.Replacement)
+			}
+
+			if path != r.URL.Path {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.Path = path
+				r2.URL.RawPath = ""
+				r = r2
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	var path, rawPath string
+	h := Apply(Rewrite([]RewriteRule{
+		{regexp.MustCompile(`^/v1(/.*)$`), "$1"},
+		{regexp.MustCompile(`^/u/(?P<id>[^/]+)$`), "/users/${id}"},
+	}), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		rawPath = r.URL.RawPath
+	})
+
+	send := func(url string) *http.Request {
+		r, _ := http.NewRequest("GET", url, nil)
+		h.ServeHTTP(nil, r)
+		return r
+	}
+
+	// Rules are applied in order.
+	r := send("/v1/u/123")
+	assert.Equal(t, "/users/123", path)
+	assert.Equal(t, "/v1/u/123", r.URL.Path)
+
+	// Unmatched paths are left alone.
+	send("/other")
+	assert.Equal(t, "/other", path)
+
+	// The raw path is kept consistent with the rewritten path.
+	send("/v1/a%2Fb")
+	assert.Equal(t, "/a/b", path)
+	assert.Equal(t, "", rawPath)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// ShutdownAware returns a middleware that stores the given channel in the
+// context of each request, from where it can be retrieved with
+// GetShutdownSignal.  The channel should be closed when the server begins
+// shutting down, so that long-running handlers (e.g. long-polling) can select
+// on it and return early, rather than being killed when the server exits.
+func ShutdownAware(done <-chan struct{}) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = context.WithValue(*ctx, shutdownKey, done)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetShutdownSignal retrieves the shutdown channel from the given context, or
+// nil if the context does not contain one.  Since receiving from a nil channel
+// blocks forever, the result can always be used in a select statement.
+func GetShutdownSignal(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(shutdownKey).(<-chan struct{})
+	return done
+}
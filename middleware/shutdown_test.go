@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestShutdownAware(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan struct{})
+
+	var (
+		before bool
+		after  bool
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		sig := GetShutdownSignal(ctx)
+
+		select {
+		case <-sig:
+			before = true
+		default:
+		}
+
+		close(done)
+
+		select {
+		case <-sig:
+			after = true
+		default:
+		}
+	}, nil)
+	stack.Push(ShutdownAware(done))
+
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+
+	assert.False(t, before)
+	assert.True(t, after)
+
+	// Contexts without a signal return a nil channel.
+	assert.Nil(t, GetShutdownSignal(context.Background()))
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// sniffedBody is a request body that reads through the bufio.Reader used to
+// peek at it, so that the peeked bytes are still read by the handler.
+type sniffedBody struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// readErrorStatus returns the status code to respond with when a request's
+// body can't be read because of the given error.
+func readErrorStatus(err error) int {
+	var (
+		mbe *http.MaxBytesError
+		ne  net.Error
+	)
+	switch {
+	case errors.As(err, &mbe):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &ne) && ne.Timeout():
+		return http.StatusRequestTimeout
+	}
+	return http.StatusBadRequest
+}
+
+// SniffBody returns a middleware that peeks at the first n bytes of the
+// request body (or the whole body, if it is shorter) and passes them to
+// decide.  If decide returns an error, the request is rejected with a 400 Bad
+// Request response.  Otherwise, the handler is called with a body that yields
+// the complete stream, starting with the peeked bytes.
+//
+// If the body can't be read, the request is rejected with a 413 Request
+// Entity Too Large response if the body exceeds a limit set with
+// http.MaxBytesReader (e.g. by MaxBodyBytes), a 408 Request Timeout response
+// if reading it timed out, or a 400 Bad Request response otherwise.
+//
+// Only the first n bytes are buffered, so this is suitable for large uploads
+// (e.g. to check a file's magic bytes).  The slice given to decide must not be
+// retained after it returns.  SniffBody panics if n is not positive.
+func SniffBody(n int, decide func([]byte) error) func(http.Handler) http.Handler {
+	if n <= 0 {
+		panic("middleware: SniffBody needs a positive number of bytes to sniff")
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				br     *bufio.Reader
+				prefix []byte
+			)
+			if r.Body != nil {
+				var err error
+				br = bufio.NewReaderSize(r.Body, n)
+				prefix, err = br.Peek(n)
+				if err != nil && err != io.EOF {
+					code := readErrorStatus(err)
+					http.Error(w, http.StatusText(code), code)
+					return
+				}
+			}
+			if err := decide(prefix); err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			if br != nil {
+				r.Body = sniffedBody{br, r.Body}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSniffBody(t *testing.T) {
+	t.Parallel()
+
+	var (
+		called bool
+		body   []byte
+		peeked []byte
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		called = true
+		body, _ = ioutil.ReadAll(r.Body)
+	}, nil)
+	stack.Push(SniffBody(4, func(prefix []byte) error {
+		peeked = append([]byte(nil), prefix...)
+		if !bytes.HasPrefix(prefix, []byte("GIF8")) {
+			return errors.New("not a GIF")
+		}
+		return nil
+	}))
+
+	send := func(s string) *httptest.ResponseRecorder {
+		called = false
+		body = nil
+
+		si := stack.Get()
+		defer stack.Release(si)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(s))
+		si.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// The handler sees the whole body, including the peeked bytes.
+	large := "GIF89a" + strings.Repeat("x", 64*1024)
+	w := send(large)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+	assert.Equal(t, "GIF8", string(peeked))
+	assert.Equal(t, large, string(body))
+
+	// Rejected bodies never reach the handler.
+	w = send("\x89PNG\r\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, called)
+
+	// Bodies shorter than the window are passed to decide in full.
+	w = send("GI")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "GI", string(peeked))
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// errorReader fails every read with the given error.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestSniffBodyErrors(t *testing.T) {
+	t.Parallel()
+
+	assert.Panics(t, func() {
+		SniffBody(0, func([]byte) error { return nil })
+	})
+
+	var called bool
+	h := Apply(SniffBody(4, func([]byte) error { return nil }), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	send := func(body io.Reader, limit int64) int {
+		called = false
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/", body)
+		if limit > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Read errors are reported with an appropriate status.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(strings.NewReader("GIF89a"), 2))
+	assert.Equal(t, http.StatusRequestTimeout, send(errorReader{timeoutError{}}, 0))
+	assert.Equal(t, http.StatusBadRequest, send(errorReader{io.ErrUnexpectedEOF}, 0))
+	assert.False(t, called)
+
+	assert.Equal(t, http.StatusOK, send(strings.NewReader("GIF89a"), 1024))
+	assert.True(t, called)
+}
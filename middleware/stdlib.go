@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// FromStdlib adapts a standard library-style middleware that uses the
+// request's context (i.e. r.Context() and r.WithContext) into one that uses
+// the wolf context.
+//
+// Before the given middleware runs, the wolf context is copied into the
+// request's context.  When the middleware calls the next handler, the
+// request's context (including any values the middleware added to it) is
+// copied back into the wolf context, so that it is visible to later middleware
+// and the final handler.  The next handler is also given the request as
+// modified by the middleware.
+//
+// Each copy into the request's context requires a shallow copy of the request,
+// so there is a small per-request cost to using this adapter.
+func FromStdlib(mw func(http.Handler) http.Handler) func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = r.Context()
+			h.ServeHTTP(w, r)
+		})
+		wrapped := mw(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped.ServeHTTP(w, r.WithContext(*ctx))
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	stdcontext "context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFromStdlib(t *testing.T) {
+	t.Parallel()
+
+	var (
+		seenByStdlib  interface{}
+		seenByHandler interface{}
+	)
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		seenByHandler = ctx.Value("stdlib")
+	}, nil)
+
+	// A wolf middleware sets a value...
+	stack.Push(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = context.WithValue(*ctx, "wolf", "value")
+			h.ServeHTTP(w, r)
+		})
+	})
+
+	// ... that is visible to a standard library middleware, which sets a
+	// value of its own ...
+	stack.Push(FromStdlib(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seenByStdlib = r.Context().Value("wolf")
+			ctx := stdcontext.WithValue(r.Context(), "stdlib", "value")
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}))
+
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+
+	// ... which is visible to the handler.
+	assert.Equal(t, "value", seenByStdlib)
+	assert.Equal(t, "value", seenByHandler)
+}
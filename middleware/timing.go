@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// requestTiming holds the timing information for a single request.
+type requestTiming struct {
+	start   time.Time
+	elapsed time.Duration
+	done    bool
+}
+
+// Timing returns a middleware that records the time at which each request
+// started in the context, from where it can be retrieved with
+// GetRequestStart.  Once the downstream handler returns, the total time taken
+// is also recorded, and can be retrieved with Elapsed.
+//
+// Since every middleware in a stack shares the same context, a middleware that
+// wraps Timing (e.g. one that reports metrics) can read the final duration
+// from the context after its own call to the next handler returns.
+func Timing() func(*context.Context, http.Handler) http.Handler {
+	return func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &requestTiming{start: time.Now()}
+			*ctx = context.WithValue(*ctx, timingKey, t)
+
+			h.ServeHTTP(w, r)
+
+			t.elapsed = time.Since(t.start)
+			t.done = true
+		})
+	}
+}
+
+// GetRequestStart retrieves the time at which the current request started
+// from the given context.  The boolean is false if the Timing middleware
+// hasn't been run.
+func GetRequestStart(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(timingKey).(*requestTiming)
+	if !ok {
+		return time.Time{}, false
+	}
+	return t.start, true
+}
+
+// Elapsed returns the time taken by the current request.  If the request is
+// still being handled, this is the time since it started; otherwise, it's the
+// total time recorded by the Timing middleware.  The boolean is false if the
+// Timing middleware hasn't been run.
+func Elapsed(ctx context.Context) (time.Duration, bool) {
+	t, ok := ctx.Value(timingKey).(*requestTiming)
+	if !ok {
+		return 0, false
+	}
+	if t.done {
+		return t.elapsed, true
+	}
+	return time.Since(t.start), true
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	var (
+		start       time.Time
+		handlerOk   bool
+		total       time.Duration
+		totalOk     bool
+		totalRepeat time.Duration
+	)
+	before := time.Now()
+	stack := New(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		start, handlerOk = GetRequestStart(ctx)
+		time.Sleep(10 * time.Millisecond)
+	}, nil)
+
+	// A "metrics" middleware that wraps Timing, and reads the duration once
+	// the request has finished.
+	stack.Push(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			total, totalOk = Elapsed(*ctx)
+			time.Sleep(5 * time.Millisecond)
+			totalRepeat, _ = Elapsed(*ctx)
+		})
+	})
+	stack.Push(Timing())
+
+	si := stack.Get()
+	sendRequest(si.Handler)
+	stack.Release(si)
+
+	assert.True(t, handlerOk)
+	assert.False(t, start.Before(before))
+	assert.True(t, totalOk)
+	assert.True(t, total >= 10*time.Millisecond)
+
+	// Once the request has finished, the elapsed time is fixed.
+	assert.Equal(t, total, totalRepeat)
+
+	// Contexts without timing information.
+	_, ok := GetRequestStart(context.Background())
+	assert.False(t, ok)
+	_, ok = Elapsed(context.Background())
+	assert.False(t, ok)
+}
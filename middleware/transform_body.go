@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// transformWriter buffers the body of responses whose content type matches,
+// passing all other responses straight through.
+type transformWriter struct {
+	http.ResponseWriter
+	match func(string) bool
+
+	wroteHeader bool
+	buffering   bool
+	code        int
+	buf         bytes.Buffer
+}
+
+func (w *transformWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	// Responses that can't have a body are never transformed.
+	bodyless := code < 200 || code == http.StatusNoContent || code == http.StatusNotModified
+	if !bodyless && w.match(w.Header().Get("Content-Type")) {
+		w.buffering = true
+		w.code = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *transformWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		// As with net/http, sniff the content type if it isn't set.
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.  Buffered responses can't be flushed until
+// they have been transformed, so this does nothing for them.  This also stops
+// http.ResponseController from flushing the original writer (see Unwrap).
+func (w *transformWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish transforms and writes out a buffered response.
+func (w *transformWriter) finish(transform func([]byte) []byte) {
+	if !w.buffering {
+		return
+	}
+
+	body := transform(w.buf.Bytes())
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(body)
+}
+
+// TransformBody returns a middleware that post-processes response bodies - for
+// example, to inject a script tag into HTML pages, or to minify JSON.  If the
+// response's Content-Type (as set when the handler writes the status, or sniffed
+// from the first write, as with net/http) satisfies match, the entire body is
+// buffered until the handler returns, then passed through transform, and written
+// out with an updated Content-Length header.
+//
+// Responses that don't match are streamed through untouched, so that they
+// don't pay the cost of buffering, and can be flushed.  Flushing a buffered
+// response does nothing.  Responses that can't have a body (such as
+// 204 No Content and 304 Not Modified) are never transformed.
+func TransformBody(match func(contentType string) bool, transform func([]byte) []byte) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tw := &transformWriter{ResponseWriter: w, match: match}
+			h.ServeHTTP(tw, r)
+			tw.finish(transform)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTransformBody(t *testing.T) {
+	t.Parallel()
+
+	isHTML := func(ct string) bool {
+		return strings.HasPrefix(ct, "text/html")
+	}
+	inject := func(b []byte) []byte {
+		return bytes.Replace(b, []byte("</body>"), []byte(`<script src="/live.js"></script></body>`), 1)
+	}
+
+	var (
+		ctype  string
+		status int
+	)
+	h := Apply(TransformBody(isHTML, inject), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+		w.Header().Set("Content-Length", "28")
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+		w.Write([]byte("<html><body>hi</body></html>"))
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// HTML responses are transformed, keeping their status.
+	ctype, status = "text/html; charset=utf-8", http.StatusCreated
+	w := send()
+	expected := `<html><body>hi<script src="/live.js"></script></body></html>`
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+	assert.Equal(t, strconv.Itoa(len(expected)), w.Header().Get("Content-Length"))
+
+	// Sniffed content types are matched too.
+	ctype, status = "", 0
+	w = send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+
+	// Other responses pass through untouched.
+	ctype = "text/plain"
+	w = send()
+	assert.Equal(t, "<html><body>hi</body></html>", w.Body.String())
+	assert.Equal(t, "28", w.Header().Get("Content-Length"))
+}
+
+func TestTransformBodyFlush(t *testing.T) {
+	t.Parallel()
+
+	isHTML := func(ct string) bool {
+		return strings.HasPrefix(ct, "text/html")
+	}
+	upper := func(b []byte) []byte {
+		return bytes.ToUpper(b)
+	}
+
+	var ctype string
+	h := Apply(TransformBody(isHTML, upper), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ctype)
+		w.Write([]byte("hello"))
+		http.NewResponseController(w).Flush()
+		w.Write([]byte(" world"))
+	})
+
+	// Flushing a buffered response doesn't commit the headers early.
+	ctype = "text/html"
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	assert.False(t, w.Flushed)
+	assert.Equal(t, "HELLO WORLD", w.Body.String())
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+
+	// Other responses are flushed as usual.
+	ctype = "text/plain"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "hello world", w.Body.String())
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// WrapResponseWriter is a http.ResponseWriter that keeps track of the response
+// that has been written through it.  Middleware that need to inspect the
+// response (e.g. its status code) should wrap the ResponseWriter they are
+// given with WrapWriter.
+type WrapResponseWriter interface {
+	http.ResponseWriter
+
+	// Status returns the HTTP status code of the response, or 0 if the
+	// response has not been written yet.
+	Status() int
+
+	// BytesWritten returns the number of bytes of the response body that
+	// have been written.
+	BytesWritten() int
+
+	// Written returns whether the response's header has been written.
+	Written() bool
+
+	// Unwrap returns the original http.ResponseWriter.
+	Unwrap() http.ResponseWriter
+}
+
+// WrapWriter wraps the given http.ResponseWriter in a WrapResponseWriter.  If
+// the original writer implements http.Flusher, http.Hijacker or http.Pusher,
+// the returned writer will too, where it makes sense to do so.
+func WrapWriter(w http.ResponseWriter) WrapResponseWriter {
+	_, fl := w.(http.Flusher)
+	_, hj := w.(http.Hijacker)
+	_, ps := w.(http.Pusher)
+
+	bw := basicWriter{ResponseWriter: w}
+	switch {
+	case fl && hj:
+		return &fancyWriter{bw}
+	case fl && ps:
+		return &http2Writer{bw}
+	case fl:
+		return &flushWriter{bw}
+	}
+	return &bw
+}
+
+// basicWriter wraps a http.ResponseWriter that implements the minimal
+// http.ResponseWriter interface.
+type basicWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	code        int
+	bytes       int
+}
+
+func (b *basicWriter) WriteHeader(code int) {
+	if !b.wroteHeader {
+		b.code = code
+		b.wroteHeader = true
+	}
+	b.ResponseWriter.WriteHeader(code)
+}
+
+func (b *basicWriter) Write(buf []byte) (int, error) {
+	if !b.wroteHeader {
+		b.WriteHeader(http.StatusOK)
+	}
+
+	n, err := b.ResponseWriter.Write(buf)
+	b.bytes += n
+	return n, err
+}
+
+func (b *basicWriter) Status() int {
+	return b.code
+}
+
+func (b *basicWriter) BytesWritten() int {
+	return b.bytes
+}
+
+func (b *basicWriter) Written() bool {
+	return b.wroteHeader
+}
+
+func (b *basicWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// flushWriter is a writer that additionally satisfies http.Flusher.
+type flushWriter struct {
+	basicWriter
+}
+
+func (f *flushWriter) Flush() {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	f.basicWriter.ResponseWriter.(http.Flusher).Flush()
+}
+
+// fancyWriter is a HTTP/1 writer that additionally satisfies http.Flusher
+// and http.Hijacker.
+type fancyWriter struct {
+	basicWriter
+}
+
+func (f *fancyWriter) Flush() {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	f.basicWriter.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (f *fancyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return f.basicWriter.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// http2Writer is a HTTP/2 writer that additionally satisfies http.Flusher
+// and http.Pusher.
+type http2Writer struct {
+	basicWriter
+}
+
+func (f *http2Writer) Flush() {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	f.basicWriter.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (f *http2Writer) Push(target string, opts *http.PushOptions) error {
+	return f.basicWriter.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+var (
+	_ http.Flusher  = &flushWriter{}
+	_ http.Flusher  = &fancyWriter{}
+	_ http.Hijacker = &fancyWriter{}
+	_ http.Flusher  = &http2Writer{}
+	_ http.Pusher   = &http2Writer{}
+)
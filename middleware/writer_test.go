@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapWriter(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	w := WrapWriter(rec)
+	assert.False(t, w.Written())
+	assert.Equal(t, 0, w.Status())
+
+	// The recorder supports flushing, so the wrapper should too.
+	_, ok := w.(http.Flusher)
+	assert.True(t, ok)
+
+	w.Write([]byte("hello"))
+	assert.True(t, w.Written())
+	assert.Equal(t, http.StatusOK, w.Status())
+	assert.Equal(t, 5, w.BytesWritten())
+	assert.Equal(t, rec, w.Unwrap())
+
+	// Only the first status is recorded.
+	w = WrapWriter(httptest.NewRecorder())
+	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(http.StatusOK)
+	assert.Equal(t, http.StatusNotFound, w.Status())
+}
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestWrapWriterPlain(t *testing.T) {
+	t.Parallel()
+
+	w := WrapWriter(plainWriter{httptest.NewRecorder()})
+	_, ok := w.(http.Flusher)
+	assert.False(t, ok)
+}
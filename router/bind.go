@@ -0,0 +1,111 @@
+package router
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// ErrInvalidBindTarget is returned from BindParams when the destination is not
+// a non-nil pointer to a struct.
+var ErrInvalidBindTarget = errors.New("router: BindParams requires a non-nil pointer to a struct")
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// BindParams populates the fields of the struct pointed to by dest from the
+// URL parameters in the given context (see GetURLParams).  Each field to be
+// bound is tagged with the name of its parameter, e.g.:
+//
+//	var params struct {
+//		ID     int    `wolf:"id"`
+//		Format string `wolf:"format,omitempty"`
+//	}
+//	err := router.BindParams(ctx, &params)
+//
+// Parameters are converted to the type of their field, which may be a string,
+// bool, integer or floating-point type, or any type whose pointer implements
+// encoding.TextUnmarshaler.  Untagged fields, and fields tagged with "-", are
+// left alone.
+//
+// A parameter is required unless its tag has the "omitempty" option, in which
+// case its field is left unchanged if the parameter is missing.  An error is
+// returned for the first field with a missing or unconvertible parameter.
+func BindParams(ctx context.Context, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+	v = v.Elem()
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("wolf")
+		if tag == "" || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+
+		val, ok := GetURLParam(ctx, name)
+		if !ok {
+			if opts == "omitempty" {
+				continue
+			}
+			return fmt.Errorf("router: missing URL parameter %q", name)
+		}
+
+		if err := setField(v.Field(i), val); err != nil {
+			return fmt.Errorf("router: URL parameter %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setField converts the given string to the type of the given field, and sets
+// the field to the result.
+func setField(f reflect.Value, val string) error {
+	if f.CanAddr() && f.Addr().Type().Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to bool", val)
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", val, f.Type())
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", val, f.Type())
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", val, f.Type())
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
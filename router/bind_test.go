@@ -0,0 +1,83 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// upperString tests binding into a TextUnmarshaler.
+type upperString string
+
+func (u *upperString) UnmarshalText(b []byte) error {
+	*u = upperString(strings.ToUpper(string(b)))
+	return nil
+}
+
+type bindTarget struct {
+	ID       int         `wolf:"id"`
+	Slug     string      `wolf:"slug"`
+	Draft    bool        `wolf:"draft"`
+	Page     uint8       `wolf:"page,omitempty"`
+	Score    float64     `wolf:"score,omitempty"`
+	Code     upperString `wolf:"code,omitempty"`
+	Ignored  string      `wolf:"-"`
+	Untagged string
+}
+
+func TestBindParams(t *testing.T) {
+	t.Parallel()
+
+	ctx := SetURLParams(context.Background(), map[string]string{
+		"id":      "42",
+		"slug":    "hello-world",
+		"draft":   "true",
+		"score":   "1.5",
+		"code":    "abc",
+		"Ignored": "x",
+	})
+
+	dest := bindTarget{Page: 7, Untagged: "keep"}
+	if assert.NoError(t, BindParams(ctx, &dest)) {
+		assert.Equal(t, bindTarget{
+			ID:       42,
+			Slug:     "hello-world",
+			Draft:    true,
+			Page:     7,
+			Score:    1.5,
+			Code:     "ABC",
+			Untagged: "keep",
+		}, dest)
+	}
+}
+
+func TestBindParamsErrors(t *testing.T) {
+	t.Parallel()
+
+	bind := func(params map[string]string) error {
+		var dest bindTarget
+		return BindParams(SetURLParams(context.Background(), params), &dest)
+	}
+
+	assert.EqualError(t, bind(map[string]string{"id": "1", "slug": "x"}),
+		`router: missing URL parameter "draft"`)
+	assert.EqualError(t, bind(map[string]string{"id": "abc", "slug": "x", "draft": "1"}),
+		`router: URL parameter "id": cannot convert "abc" to int`)
+	assert.EqualError(t, bind(map[string]string{"id": "1", "slug": "x", "draft": "maybe"}),
+		`router: URL parameter "draft": cannot convert "maybe" to bool`)
+	assert.EqualError(t, bind(map[string]string{"id": "1", "slug": "x", "draft": "1", "page": "300"}),
+		`router: URL parameter "page": cannot convert "300" to uint8`)
+
+	var unsupported struct {
+		Tags []string `wolf:"tags"`
+	}
+	ctx := SetURLParams(context.Background(), map[string]string{"tags": "a,b"})
+	assert.EqualError(t, BindParams(ctx, &unsupported),
+		`router: URL parameter "tags": unsupported field type []string`)
+
+	var dest bindTarget
+	assert.Equal(t, ErrInvalidBindTarget, BindParams(ctx, dest))
+	assert.Equal(t, ErrInvalidBindTarget, BindParams(ctx, (*bindTarget)(nil)))
+}
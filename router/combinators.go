@@ -0,0 +1,207 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// andPattern matches if all of its patterns match.
+type andPattern []Pattern
+
+func (p andPattern) Prefix() string {
+	// Every pattern must match, so any of their prefixes is a valid prefix -
+	// we pick the longest, since it's the most selective.
+	prefix := ""
+	for _, pat := range p {
+		if pp := pat.Prefix(); len(pp) > len(prefix) {
+			prefix = pp
+		}
+	}
+	return prefix
+}
+
+func (p andPattern) Match(r *http.Request) bool {
+	return p.MatchContext(r, context.Background())
+}
+
+// MatchContext implements ContextMatcher, so that an And nested in another
+// combinator sees the parameters bound before it.
+func (p andPattern) MatchContext(r *http.Request, base context.Context) bool {
+	var ctx context.Context
+	for i, pat := range p {
+		if cm, ok := pat.(ContextMatcher); ok {
+			// Bind the parameters from the earlier patterns, which we know
+			// match, so that this one can inspect them.
+			if ctx == nil {
+				ctx = base
+				for _, prev := range p[:i] {
+					prev.Run(r, &ctx)
+				}
+			}
+			if !cm.MatchContext(r, ctx) {
+				return false
+			}
+		} else if !pat.Match(r) {
+			return false
+		}
+
+		if ctx != nil {
+			pat.Run(r, &ctx)
+		}
+	}
+	return true
+}
+
+func (p andPattern) Run(r *http.Request, c *context.Context) {
+	// Like the built-in patterns, don't bind anything unless we match.
+	if !p.MatchContext(r, *c) {
+		return
+	}
+
+	for _, pat := range p {
+		pat.Run(r, c)
+	}
+}
+
+func (p andPattern) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("And(")
+	for i, pat := range p {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprint(&buf, pat)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// And returns a Pattern that matches a request only if all of the given
+// patterns match it.  Running the pattern runs each of the given patterns in
+// order, so the URL parameters bound by each are merged; if two patterns bind
+// a parameter with the same name, the later pattern's value is used.
+//
+// Patterns implementing ContextMatcher (e.g. ParamConstraint) are matched
+// against the parameters bound by the patterns before them, so they must come
+// after the patterns that bind the parameters they inspect.
+func And(patterns ...Pattern) Pattern {
+	return andPattern(patterns)
+}
+
+// orPattern matches if any of its patterns match.
+type orPattern []Pattern
+
+func (p orPattern) Prefix() string {
+	// Any of the patterns may match, so only the prefix that they all share
+	// is guaranteed.
+	if len(p) == 0 {
+		return ""
+	}
+
+	prefix := p[0].Prefix()
+	for _, pat := range p[1:] {
+		pp := pat.Prefix()
+		i := 0
+		for i < len(prefix) && i < len(pp) && prefix[i] == pp[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return prefix
+}
+
+// match returns the first of the patterns that matches the request, given the
+// parameters bound in ctx.
+func (p orPattern) match(r *http.Request, ctx context.Context) Pattern {
+	for _, pat := range p {
+		if matchContext(pat, r, ctx) {
+			return pat
+		}
+	}
+	return nil
+}
+
+func (p orPattern) Match(r *http.Request) bool {
+	return p.MatchContext(r, context.Background())
+}
+
+// MatchContext implements ContextMatcher, passing the context on to each
+// alternative.
+func (p orPattern) MatchContext(r *http.Request, ctx context.Context) bool {
+	return p.match(r, ctx) != nil
+}
+
+func (p orPattern) Run(r *http.Request, c *context.Context) {
+	if pat := p.match(r, *c); pat != nil {
+		pat.Run(r, c)
+	}
+}
+
+func (p orPattern) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("Or(")
+	for i, pat := range p {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprint(&buf, pat)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// Or returns a Pattern that matches a request if any of the given patterns
+// match it.  Running the pattern runs only the first of the given patterns
+// that matches, so the URL parameters bound are exactly those of that pattern.
+// Its prefix is the longest prefix shared by all of the given patterns.
+func Or(patterns ...Pattern) Pattern {
+	return orPattern(patterns)
+}
+
+// notPattern matches if its pattern does not match.
+type notPattern struct {
+	pat Pattern
+}
+
+func (p notPattern) Prefix() string {
+	// A negated pattern can match paths that don't start with the inner
+	// pattern's prefix, so we can't make any guarantees.
+	return ""
+}
+
+func (p notPattern) Match(r *http.Request) bool {
+	return p.MatchContext(r, context.Background())
+}
+
+// MatchContext implements ContextMatcher, passing the context on to the
+// negated pattern.
+func (p notPattern) MatchContext(r *http.Request, ctx context.Context) bool {
+	return !matchContext(p.pat, r, ctx)
+}
+
+func (p notPattern) Run(r *http.Request, c *context.Context) {
+	// Nothing matched, so there is nothing to bind.
+}
+
+func (p notPattern) String() string {
+	return fmt.Sprintf("Not(%v)", p.pat)
+}
+
+// matchContext matches a pattern against a request, passing it the given
+// context if it is a ContextMatcher.
+func matchContext(p Pattern, r *http.Request, ctx context.Context) bool {
+	if cm, ok := p.(ContextMatcher); ok {
+		return cm.MatchContext(r, ctx)
+	}
+	return p.Match(r)
+}
+
+// Not returns a Pattern that matches a request only if the given pattern does
+// not match it.  Running the returned pattern does nothing.  It is most useful
+// in combination with And, to exclude some requests from another pattern.
+func Not(p Pattern) Pattern {
+	return notPattern{p}
+}
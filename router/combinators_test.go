@@ -0,0 +1,103 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnd(t *testing.T) {
+	t.Parallel()
+
+	p := And(ParseStringPattern("/api/*"), ParseStringPattern("/api/:version/*rest"))
+	assert.Equal(t, "/api/", p.Prefix())
+
+	test := pt("/api/v1/users", true, map[string]string{
+		"*":       "/v1/users",
+		"version": "v1",
+		"rest":    "/users",
+	})
+	runTest(t, p, test)
+	runTest(t, p, pt("/api/", false, nil))
+	runTest(t, p, pt("/other/v1/users", false, nil))
+}
+
+func TestOr(t *testing.T) {
+	t.Parallel()
+
+	p := Or(ParseStringPattern("/users/:id"), ParseStringPattern("/u/:name/:id"))
+	assert.Equal(t, "/u", p.Prefix())
+
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/u/bob/456", true, map[string]string{
+		"name": "bob",
+		"id":   "456",
+	}))
+	runTest(t, p, pt("/users/123/edit", false, nil))
+	runTest(t, p, pt("/other", false, nil))
+
+	// The first matching pattern is the one that's run.
+	p = Or(ParseStringPattern("/files/:name"), ParseStringPattern("/files/*"))
+	assert.Equal(t, "/files/", p.Prefix())
+	runTest(t, p, pt("/files/a.txt", true, map[string]string{
+		"name": "a.txt",
+	}))
+	runTest(t, p, pt("/files/a/b.txt", true, map[string]string{
+		"*": "/a/b.txt",
+	}))
+
+	assert.Equal(t, "", Or().Prefix())
+	runTest(t, Or(), pt("/", false, nil))
+}
+
+func TestNot(t *testing.T) {
+	t.Parallel()
+
+	p := Not(ParseStringPattern("/api/health"))
+	assert.Equal(t, "", p.Prefix())
+	runTest(t, p, pt("/api/health", false, nil))
+	runTest(t, p, pt("/api/users", true, nil))
+
+	p = And(ParseStringPattern("/api/*"), Not(ParseStringPattern("/api/health")))
+	assert.Equal(t, "/api/", p.Prefix())
+	runTest(t, p, pt("/api/health", false, nil))
+	runTest(t, p, pt("/api/users", true, map[string]string{
+		"*": "/users",
+	}))
+}
+
+func TestMethodsInCombinators(t *testing.T) {
+	t.Parallel()
+
+	// A Methods pattern wrapping a ParamConstraint still sees the parameters
+	// bound by the patterns before it.
+	digits := regexp.MustCompile(`^\d+$`)
+	users := ParseStringPattern("/users/:id")
+	p := And(users, Methods(ParamConstraint("id", digits), "GET"))
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/users/bob", false, nil))
+
+	r, _ := http.NewRequest("POST", "/users/123", nil)
+	assert.False(t, p.Match(r))
+
+	// The same applies when nested in Or and Not.
+	p = And(users, Or(
+		Methods(ParamConstraint("id", digits), "GET"),
+		Methods(Not(ParamConstraint("id", digits)), "POST"),
+	))
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/users/bob", false, nil))
+
+	r, _ = http.NewRequest("POST", "/users/bob", nil)
+	assert.True(t, p.Match(r))
+	r, _ = http.NewRequest("POST", "/users/123", nil)
+	assert.False(t, p.Match(r))
+}
@@ -1,24 +1,259 @@
 package router
 
 import (
+	"strconv"
+	"strings"
+	"sync"
+
 	"golang.org/x/net/context"
 )
 
-type private struct{}
+type private int
 
-var urlParamKey private
+const (
+	urlParamKey private = iota
+	valueStoreKey
+	matchedMethodKey
+	errorKey
+	routeMetaKey
+	allowedMethodsKey
+	matrixParamKey
+	positionalParamKey
+	encodersKey
+)
 
 // SetURLParams will add the given URL parameters to the given context.
 func SetURLParams(ctx context.Context, matches map[string]string) context.Context {
 	return context.WithValue(ctx, urlParamKey, matches)
 }
 
-// GetURLParams will retrieve the URL parameters map from the given context.
+// MergeURLParams adds the given URL parameters to the given context, keeping
+// any parameters that are already present.  If a parameter is present in both,
+// the new value is used.  Patterns use this when they bind parameters, so that
+// a handler within a nested router (see StripPrefix) sees the parameters bound
+// at every level.
+func MergeURLParams(ctx context.Context, matches map[string]string) context.Context {
+	existing := GetURLParams(ctx)
+	if len(existing) == 0 {
+		return SetURLParams(ctx, matches)
+	}
+
+	merged := make(map[string]string, len(existing)+len(matches))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range matches {
+		merged[k] = v
+	}
+	return SetURLParams(ctx, merged)
+}
+
+// singleParam is a lighter representation of a URL parameters map containing
+// a single parameter, which is by far the most common case.
+type singleParam struct {
+	name, value string
+}
+
+// setSingleURLParam is like MergeURLParams with a single parameter, but if
+// there are no existing parameters, avoids allocating a map.
+func setSingleURLParam(ctx context.Context, name, value string) context.Context {
+	if ctx.Value(urlParamKey) == nil {
+		return context.WithValue(ctx, urlParamKey, singleParam{name, value})
+	}
+	return MergeURLParams(ctx, map[string]string{name: value})
+}
+
+// GetURLParams will retrieve the URL parameters map from the given context,
+// or nil if there is none.  If the route bound a single parameter, the map is
+// allocated on every call, so GetURLParam should be preferred for looking up
+// individual parameters.
 func GetURLParams(ctx context.Context) map[string]string {
-	val := ctx.Value(urlParamKey)
-	if val == nil {
-		return nil
+	switch params := ctx.Value(urlParamKey).(type) {
+	case map[string]string:
+		return params
+	case singleParam:
+		return map[string]string{params.name: params.value}
+	}
+	return nil
+}
+
+// GetURLParam retrieves a single URL parameter from the given context.  The
+// boolean is false if the parameter isn't present.  This is cheaper than
+// looking the parameter up in the map returned from GetURLParams, which may
+// need to be allocated.
+func GetURLParam(ctx context.Context, name string) (string, bool) {
+	switch params := ctx.Value(urlParamKey).(type) {
+	case map[string]string:
+		val, ok := params[name]
+		return val, ok
+	case singleParam:
+		if params.name == name {
+			return params.value, true
+		}
 	}
+	return "", false
+}
+
+// GetURLParamByIndex retrieves the i'th capture group (numbered from 1, as in
+// the "$N" parameter names) bound by a regexp pattern, regardless of whether
+// the group is named.  The boolean is false if there is no such group.
+func GetURLParamByIndex(ctx context.Context, i int) (string, bool) {
+	captures, _ := ctx.Value(positionalParamKey).([]string)
+	if i < 1 || i > len(captures) {
+		return "", false
+	}
+	return captures[i-1], true
+}
+
+// GetURLParamAny retrieves a URL parameter by either its name, or (for
+// parameters bound by a regexp pattern) its position in the form "$N".  Unlike
+// looking up "$N" in GetURLParams, which only contains unnamed groups, this
+// also finds named groups by their position.
+func GetURLParamAny(ctx context.Context, key string) (string, bool) {
+	if val, ok := GetURLParam(ctx, key); ok {
+		return val, true
+	}
+
+	if strings.HasPrefix(key, "$") {
+		if i, err := strconv.Atoi(key[1:]); err == nil {
+			return GetURLParamByIndex(ctx, i)
+		}
+	}
+	return "", false
+}
+
+// SetMatrixParams will add the given matrix parameters to the given context.
+func SetMatrixParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, matrixParamKey, params)
+}
+
+// GetMatrixParams will retrieve the matrix parameters bound by a string
+// pattern with the MatrixParams option (see StringPatternOptions) from the
+// given context, or nil if there are none.
+func GetMatrixParams(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(matrixParamKey).(map[string]string)
+	return params
+}
+
+// SetMatchedMethod records the HTTP method that a router matched the current
+// route under in the given context.
+func SetMatchedMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, matchedMethodKey, method)
+}
 
-	return val.(map[string]string)
+// GetMatchedMethod retrieves the HTTP method that the router matched the
+// current route under.  This may differ from the request's method - e.g. when
+// SimpleRouter's NormalizeMethod option is used.  The boolean is false if no
+// method has been recorded.
+func GetMatchedMethod(ctx context.Context) (string, bool) {
+	method, ok := ctx.Value(matchedMethodKey).(string)
+	return method, ok
+}
+
+// SetRouteMeta records the metadata of the matched route in the given context.
+func SetRouteMeta(ctx context.Context, meta map[string]interface{}) context.Context {
+	return context.WithValue(ctx, routeMetaKey, meta)
+}
+
+// GetRouteMeta retrieves the metadata of the matched route (see
+// builder.RouteConfig) from the given context, or nil if there is none.
+func GetRouteMeta(ctx context.Context) map[string]interface{} {
+	meta, _ := ctx.Value(routeMetaKey).(map[string]interface{})
+	return meta
+}
+
+// SetAllowedMethods records the methods that the request's path can be served
+// with in the given context.
+func SetAllowedMethods(ctx context.Context, methods []string) context.Context {
+	return context.WithValue(ctx, allowedMethodsKey, methods)
+}
+
+// GetAllowedMethods retrieves the methods that the request's path can be
+// served with from the given context.  SimpleRouter sets this for its
+// MethodNotAllowed handler, when a request's path matches some route, but not
+// under the request's method.
+func GetAllowedMethods(ctx context.Context) []string {
+	methods, _ := ctx.Value(allowedMethodsKey).([]string)
+	return methods
+}
+
+// valueStore is a mutable set of values, stored in a context.
+type valueStore struct {
+	mu   sync.RWMutex
+	vals map[interface{}]interface{}
+}
+
+// WithValueStore returns a copy of the given context that contains a mutable
+// store for values set with SetValue.  SimpleRouter adds a store to the
+// context of every request.  If the context already has a store, it is
+// returned unchanged, so that the handlers and middleware of nested routers
+// all share the outermost router's store.
+func WithValueStore(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(valueStoreKey).(*valueStore); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, valueStoreKey, &valueStore{})
+}
+
+// SetValue stores the given value in the context's value store (see
+// WithValueStore), and returns false if the context does not have one.
+//
+// Unlike context.WithValue, this modifies the existing context rather than
+// creating a new one.  This allows a handler, which is unable to replace the
+// context that it was given, to pass values back to the middleware that wraps
+// it: after the handler returns, the middleware will be able to retrieve the
+// value with Value.
+func SetValue(ctx context.Context, key, val interface{}) bool {
+	store, ok := ctx.Value(valueStoreKey).(*valueStore)
+	if !ok {
+		return false
+	}
+
+	store.mu.Lock()
+	if store.vals == nil {
+		store.vals = make(map[interface{}]interface{})
+	}
+	store.vals[key] = val
+	store.mu.Unlock()
+	return true
+}
+
+// Value retrieves the value associated with the given key.  Values set with
+// SetValue take precedence over those in the context itself.
+func Value(ctx context.Context, key interface{}) interface{} {
+	if store, ok := ctx.Value(valueStoreKey).(*valueStore); ok {
+		store.mu.RLock()
+		val, found := store.vals[key]
+		store.mu.RUnlock()
+
+		if found {
+			return val
+		}
+	}
+
+	return ctx.Value(key)
+}
+
+// errorHolder holds an error returned from a handler.
+type errorHolder struct {
+	err error
+}
+
+// WithErrorCapture returns a copy of the given context that captures errors
+// returned from error-returning handlers (see ErrorFunc).  When a handler that
+// doesn't implement ErrorHandler returns an error, it is stored in the context
+// instead of being passed to DefaultOnError, and can be retrieved with
+// GetError once the handler has returned.  SimpleRouter uses this to render
+// errors with its InternalError handler.
+func WithErrorCapture(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorKey, &errorHolder{})
+}
+
+// GetError retrieves the error captured in the given context (see
+// WithErrorCapture), or nil if there isn't one.
+func GetError(ctx context.Context) error {
+	if h, ok := ctx.Value(errorKey).(*errorHolder); ok {
+		return h.err
+	}
+	return nil
 }
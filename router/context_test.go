@@ -0,0 +1,137 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestSetValue(t *testing.T) {
+	t.Parallel()
+
+	// Without a store, nothing is set.
+	ctx := context.WithValue(context.Background(), "key", "orig")
+	assert.False(t, SetValue(ctx, "key", "new"))
+	assert.Equal(t, "orig", Value(ctx, "key"))
+
+	// With a store, the value is visible to the holders of both the original
+	// context and any derived ones.
+	ctx = WithValueStore(ctx)
+	derived := context.WithValue(ctx, "other", "value")
+	assert.True(t, SetValue(derived, "key", "new"))
+	assert.Equal(t, "new", Value(ctx, "key"))
+	assert.Equal(t, "new", Value(derived, "key"))
+	assert.Equal(t, "value", Value(derived, "other"))
+	assert.Nil(t, Value(ctx, "missing"))
+
+	// Adding a store to a context that already has one reuses it.
+	assert.Equal(t, ctx, WithValueStore(ctx))
+	assert.True(t, SetValue(WithValueStore(derived), "key", "newer"))
+	assert.Equal(t, "newer", Value(ctx, "key"))
+}
+
+func TestMatchedMethod(t *testing.T) {
+	t.Parallel()
+
+	_, ok := GetMatchedMethod(context.Background())
+	assert.False(t, ok)
+
+	method, ok := GetMatchedMethod(SetMatchedMethod(context.Background(), "POST"))
+	assert.True(t, ok)
+	assert.Equal(t, "POST", method)
+}
+
+func TestRouteMeta(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, GetRouteMeta(context.Background()))
+
+	meta := map[string]interface{}{"scope": "admin"}
+	assert.Equal(t, meta, GetRouteMeta(SetRouteMeta(context.Background(), meta)))
+}
+
+func TestAllowedMethods(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, GetAllowedMethods(context.Background()))
+
+	ctx := SetAllowedMethods(context.Background(), []string{"GET", "POST"})
+	assert.Equal(t, []string{"GET", "POST"}, GetAllowedMethods(ctx))
+}
+
+func TestGetURLParamAny(t *testing.T) {
+	t.Parallel()
+
+	p := ParseRegexpPattern(regexp.MustCompile(`^/(?P<kind>[a-z]+)/(\d+)/(?P<action>[a-z]+)$`))
+	r, _ := http.NewRequest("GET", "/users/42/edit", nil)
+	ctx := context.Background()
+	p.Run(r, &ctx)
+
+	var anyTests = []struct {
+		key string
+		val string
+		ok  bool
+	}{
+		{"kind", "users", true},
+		{"action", "edit", true},
+		{"$1", "users", true},
+		{"$2", "42", true},
+		{"$3", "edit", true},
+		{"$4", "", false},
+		{"$x", "", false},
+		{"missing", "", false},
+	}
+	for _, test := range anyTests {
+		val, ok := GetURLParamAny(ctx, test.key)
+		assert.Equal(t, test.ok, ok, test.key)
+		assert.Equal(t, test.val, val, test.key)
+	}
+
+	val, ok := GetURLParamByIndex(ctx, 3)
+	assert.True(t, ok)
+	assert.Equal(t, "edit", val)
+	_, ok = GetURLParamByIndex(ctx, 0)
+	assert.False(t, ok)
+
+	// Parameters from string patterns can only be found by name.
+	ctx = context.Background()
+	r, _ = http.NewRequest("GET", "/users/42", nil)
+	ParsePattern("/users/:id").Run(r, &ctx)
+	val, ok = GetURLParamAny(ctx, "id")
+	assert.True(t, ok)
+	assert.Equal(t, "42", val)
+	_, ok = GetURLParamByIndex(ctx, 1)
+	assert.False(t, ok)
+}
+
+func TestAccessorsWrongType(t *testing.T) {
+	t.Parallel()
+
+	// Values of the wrong type are treated as missing, rather than panicking.
+	ctx := context.Background()
+	for _, key := range []private{
+		urlParamKey, valueStoreKey, matchedMethodKey, errorKey,
+		routeMetaKey, allowedMethodsKey, matrixParamKey, positionalParamKey,
+	} {
+		ctx = context.WithValue(ctx, key, 1234)
+	}
+
+	assert.NotPanics(t, func() {
+		assert.Nil(t, GetURLParams(ctx))
+		assert.Nil(t, GetMatrixParams(ctx))
+		assert.Nil(t, GetRouteMeta(ctx))
+		assert.Nil(t, GetAllowedMethods(ctx))
+		assert.Nil(t, GetError(ctx))
+		assert.False(t, SetValue(ctx, "key", "val"))
+
+		_, ok := GetMatchedMethod(ctx)
+		assert.False(t, ok)
+		_, ok = GetURLParamByIndex(ctx, 1)
+		assert.False(t, ok)
+		_, ok = GetURLParamAny(ctx, "id")
+		assert.False(t, ok)
+	})
+}
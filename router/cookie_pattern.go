@@ -0,0 +1,85 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// cookiePattern matches requests carrying a given cookie.
+type cookiePattern struct {
+	name     string
+	value    string
+	anyValue bool
+
+	// URL parameter to bind the cookie's value to, if any.
+	param string
+}
+
+func (p cookiePattern) Prefix() string {
+	return ""
+}
+
+func (p cookiePattern) Match(r *http.Request) bool {
+	c, err := r.Cookie(p.name)
+	if err != nil {
+		return false
+	}
+	return p.anyValue || c.Value == p.value
+}
+
+func (p cookiePattern) Run(r *http.Request, c *context.Context) {
+	if p.param == "" {
+		return
+	}
+	cookie, err := r.Cookie(p.name)
+	if err != nil || !(p.anyValue || cookie.Value == p.value) {
+		return
+	}
+	*c = MergeURLParams(*c, map[string]string{p.param: cookie.Value})
+}
+
+func (p cookiePattern) String() string {
+	if p.param != "" {
+		return fmt.Sprintf("CookieParamPattern(%q, %q)", p.name, p.param)
+	}
+	if p.anyValue {
+		return fmt.Sprintf("CookiePattern(%q)", p.name)
+	}
+	return fmt.Sprintf("CookieValuePattern(%q, %q)", p.name, p.value)
+}
+
+// CookiePattern returns a Pattern that matches requests carrying a cookie with
+// the given name, whatever its value.  It is mostly useful when combined with a
+// path pattern using And, so that requests for the same path can be routed to
+// different handlers for (say) logged-in and anonymous users.
+//
+// Since cookies are set by the client, their values aren't bound as URL
+// parameters, where they could be mistaken for (or overwrite) parameters from
+// the path; see CookieParamPattern.
+func CookiePattern(name string) Pattern {
+	return cookiePattern{
+		name:     name,
+		anyValue: true,
+	}
+}
+
+// CookieValuePattern is like CookiePattern, but only matches requests where
+// the cookie's value is equal to value.
+func CookieValuePattern(name, value string) Pattern {
+	return cookiePattern{
+		name:  name,
+		value: value,
+	}
+}
+
+// CookieParamPattern is like CookiePattern, but running the pattern also binds
+// the cookie's value as the URL parameter with the given name.
+func CookieParamPattern(name, param string) Pattern {
+	return cookiePattern{
+		name:     name,
+		anyValue: true,
+		param:    param,
+	}
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func withCookie(path, name, value string) *http.Request {
+	r, _ := http.NewRequest("GET", path, nil)
+	if name != "" {
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	return r
+}
+
+func TestCookiePattern(t *testing.T) {
+	t.Parallel()
+
+	p := CookiePattern("session")
+	assert.Equal(t, "", p.Prefix())
+
+	assert.True(t, p.Match(withCookie("/", "session", "abc")))
+	assert.True(t, p.Match(withCookie("/", "session", "")))
+	assert.False(t, p.Match(withCookie("/", "other", "abc")))
+	assert.False(t, p.Match(withCookie("/", "", "")))
+
+	// Running the pattern doesn't bind anything.
+	ctx := context.Background()
+	p.Run(withCookie("/", "session", "abc"), &ctx)
+	assert.Nil(t, GetURLParams(ctx))
+}
+
+func TestCookieParamPattern(t *testing.T) {
+	t.Parallel()
+
+	p := CookieParamPattern("session", "sid")
+	assert.True(t, p.Match(withCookie("/", "session", "abc")))
+	assert.False(t, p.Match(withCookie("/", "", "")))
+
+	// Running the pattern binds the cookie's value.
+	ctx := context.Background()
+	p.Run(withCookie("/", "session", "abc"), &ctx)
+	assert.Equal(t, map[string]string{"sid": "abc"}, GetURLParams(ctx))
+
+	// Running a non-matching pattern doesn't change the context.
+	ctx = context.Background()
+	p.Run(withCookie("/", "", ""), &ctx)
+	assert.Nil(t, GetURLParams(ctx))
+}
+
+func TestCookieValuePattern(t *testing.T) {
+	t.Parallel()
+
+	p := CookieValuePattern("beta", "1")
+	assert.True(t, p.Match(withCookie("/", "beta", "1")))
+	assert.False(t, p.Match(withCookie("/", "beta", "0")))
+	assert.False(t, p.Match(withCookie("/", "", "")))
+
+	// Combined with a path pattern, the cookie can't overwrite a parameter
+	// from the path.
+	p = And(ParseStringPattern("/users/:id"), CookiePattern("id"))
+	r := withCookie("/users/1", "id", "2")
+	assert.True(t, p.Match(r))
+	ctx := context.Background()
+	p.Run(r, &ctx)
+	assert.Equal(t, map[string]string{"id": "1"}, GetURLParams(ctx))
+	assert.False(t, p.Match(withCookie("/users/1", "", "")))
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// Encoder writes a value to w in some format (e.g. JSON).
+type Encoder func(w io.Writer, v interface{}) error
+
+// EncoderRegistry maps content types to the Encoders that produce them, and is
+// used to render the values returned by ValueFuncs.  Encoders should all be
+// registered before the registry is used to serve requests, since it isn't
+// safe to modify concurrently.
+type EncoderRegistry struct {
+	types    []string
+	encoders map[string]Encoder
+}
+
+// NewEncoderRegistry creates an empty registry.
+func NewEncoderRegistry() *EncoderRegistry {
+	return &EncoderRegistry{encoders: make(map[string]Encoder)}
+}
+
+// Register adds an encoder for the given content type, replacing any existing
+// encoder for it.  When a request accepts several content types equally, the
+// one registered first is preferred.
+func (e *EncoderRegistry) Register(contentType string, enc Encoder) {
+	if _, ok := e.encoders[contentType]; !ok {
+		e.types = append(e.types, contentType)
+	}
+	e.encoders[contentType] = enc
+}
+
+// Negotiate returns the registered content type that is most preferred by the
+// request's Accept header (see Negotiate), along with its encoder.  The
+// boolean is false if no registered content type is acceptable.
+func (e *EncoderRegistry) Negotiate(r *http.Request) (string, Encoder, bool) {
+	ct := Negotiate(r, e.types...)
+	if ct == "" {
+		return "", nil, false
+	}
+	return ct, e.encoders[ct], true
+}
+
+// DefaultEncoders is the registry used by ValueFuncs when the context doesn't
+// have one (see WithEncoders).  It encodes JSON ("application/json", which is
+// preferred) and XML ("application/xml").
+var DefaultEncoders = NewEncoderRegistry()
+
+func init() {
+	DefaultEncoders.Register("application/json", func(w io.Writer, v interface{}) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+	DefaultEncoders.Register("application/xml", func(w io.Writer, v interface{}) error {
+		return xml.NewEncoder(w).Encode(v)
+	})
+}
+
+// WithEncoders returns a copy of the given context that uses the given
+// registry to render the values returned by ValueFuncs.  SimpleRouter adds its
+// Encoders to the context of every matched route.
+func WithEncoders(ctx context.Context, e *EncoderRegistry) context.Context {
+	return context.WithValue(ctx, encodersKey, e)
+}
+
+// GetEncoders retrieves the registry from the given context (see
+// WithEncoders), or DefaultEncoders if there isn't one.
+func GetEncoders(ctx context.Context) *EncoderRegistry {
+	if e, ok := ctx.Value(encodersKey).(*EncoderRegistry); ok {
+		return e
+	}
+	return DefaultEncoders
+}
+
+// ValueFunc is a handler that returns a value to be sent to the client, rather
+// than writing a response itself.  The value is encoded using the registry in
+// the context (see GetEncoders), in the format that the request's Accept
+// header most prefers, or a 406 Not Acceptable response is sent if none of the
+// registry's formats are acceptable.
+//
+// Like an ErrorFunc, any error returned (including one from the encoder) is
+// passed to DefaultOnError, or captured by the router; nothing is written
+// before the value is successfully encoded.
+type ValueFunc func(context.Context, *http.Request) (interface{}, error)
+
+// ServeHTTPE implements the error-returning half of ErrorHandler.
+func (f ValueFunc) ServeHTTPE(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := f(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Vary", "Accept")
+	ct, enc, ok := GetEncoders(ctx).Negotiate(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := enc(&buf, v); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ct)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type encodedUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestValueFunc(t *testing.T) {
+	t.Parallel()
+
+	h := MakeHandler(func(ctx context.Context, r *http.Request) (interface{}, error) {
+		if r.URL.Path == "/fail" {
+			return nil, errors.New("no such user")
+		}
+		return encodedUser{Name: "bob"}, nil
+	})
+
+	send := func(ctx context.Context, path, accept string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+	ctx := context.Background()
+
+	w := send(ctx, "/", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"name":"bob"}`+"\n", w.Body.String())
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	w = send(ctx, "/", "application/xml, application/json;q=0.5")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<encodedUser><name>bob</name></encodedUser>", w.Body.String())
+
+	// Without an Accept header, the first registered encoder is used.
+	w = send(ctx, "/", "")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w = send(ctx, "/", "text/html")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = send(ctx, "/fail", "application/json")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "no such user\n", w.Body.String())
+
+	// A registry in the context replaces the default one.
+	reg := NewEncoderRegistry()
+	reg.Register("text/plain", func(w io.Writer, v interface{}) error {
+		_, err := fmt.Fprintf(w, "%+v", v)
+		return err
+	})
+	ctx = WithEncoders(ctx, reg)
+
+	w = send(ctx, "/", "text/*")
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{Name:bob}", w.Body.String())
+
+	w = send(ctx, "/", "application/json")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
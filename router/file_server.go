@@ -0,0 +1,42 @@
+package router
+
+import (
+	"net/http"
+	"path"
+
+	"golang.org/x/net/context"
+)
+
+// FileServer returns a Handler that serves files from the given file system.
+// The file's name is taken from the unnamed wildcard parameter ("*") bound by
+// the matched route, if there is one, or from the request's path otherwise -
+// so a FileServer registered at "/static/*" serves the request for
+// "/static/css/site.css" from "/css/site.css" in fs.  For routes with a named
+// wildcard (e.g. "/static/*path"), use FileServerParam instead.
+//
+// Files are served with http.ServeContent, which handles Range requests (with
+// 206 Partial Content responses), conditional requests, and content-type
+// detection.  A request for a directory is served from the directory's
+// "index.html" file; directory listings are never generated.  A 404 Not Found
+// response is sent if there is no such file.
+func FileServer(fs http.FileSystem) Handler {
+	return FileServerParam(fs, "*")
+}
+
+// FileServerParam is like FileServer, but takes the file's name from the URL
+// parameter with the given name - e.g. "path" for a FileServer registered at
+// "/static/*path".
+func FileServerParam(fs http.FileSystem, param string) Handler {
+	return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		name, ok := GetURLParam(ctx, param)
+		if !ok {
+			name = r.URL.Path
+		}
+		name = path.Clean("/" + name)
+
+		if serveFile(w, r, fs, name) || serveFile(w, r, fs, path.Join(name, "index.html")) {
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
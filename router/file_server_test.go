@@ -0,0 +1,77 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFileServer(t *testing.T) {
+	t.Parallel()
+
+	h := FileServer(makeStaticDir(t))
+	send := func(ctx context.Context, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	w := send(context.Background(), "/css/site.css")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body {}", w.Body.String())
+
+	// The wildcard parameter is used as the name, if present.
+	ctx := SetURLParams(context.Background(), map[string]string{"*": "/css/site.css"})
+	w = send(ctx, "/static/css/site.css")
+	assert.Equal(t, "body {}", w.Body.String())
+
+	// Directories are served from their index file.
+	w = send(context.Background(), "/")
+	assert.Equal(t, "<html>app</html>", w.Body.String())
+	w = send(context.Background(), "/media")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(context.Background(), "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFileServerParam(t *testing.T) {
+	t.Parallel()
+
+	h := FileServerParam(makeStaticDir(t), "path")
+	pat := ParseStringPattern("/static/*path")
+
+	r, _ := http.NewRequest("GET", "/static/css/site.css", nil)
+	ctx := context.Background()
+	pat.Run(r, &ctx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(ctx, w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body {}", w.Body.String())
+}
+
+func TestFileServerRange(t *testing.T) {
+	t.Parallel()
+
+	h := FileServer(makeStaticDir(t))
+	r, _ := http.NewRequest("GET", "/media/big.txt", nil)
+	r.Header.Set("Range", "bytes=0-9")
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(context.Background(), w, r)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, "bytes 0-9/20", w.Header().Get("Content-Range"))
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+
+	// An If-Range that doesn't match the file sends the whole file.
+	r.Header.Set("If-Range", `"stale"`)
+	w = httptest.NewRecorder()
+	h.ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789abcdefghij", w.Body.String())
+}
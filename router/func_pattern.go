@@ -0,0 +1,42 @@
+package router
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// funcPattern adapts a pair of functions into something that implements the
+// Pattern interface.
+type funcPattern struct {
+	match func(*http.Request) bool
+	run   func(*http.Request, *context.Context)
+}
+
+func (p funcPattern) Prefix() string {
+	return ""
+}
+
+func (p funcPattern) Match(r *http.Request) bool {
+	return p.match(r)
+}
+
+func (p funcPattern) Run(r *http.Request, c *context.Context) {
+	if p.run != nil {
+		p.run(r, c)
+	}
+}
+
+// FuncPattern returns a Pattern that uses the given functions to match and
+// run a request, allowing arbitrary matching logic (e.g. feature flags, or
+// request time windows) to be used in a route table.  The run function is
+// optional - if it is nil, running the pattern will not modify the context.
+//
+// Since the router cannot know anything about the paths that the match
+// function accepts, the returned pattern has no prefix.
+func FuncPattern(match func(*http.Request) bool, run func(*http.Request, *context.Context)) Pattern {
+	return funcPattern{
+		match: match,
+		run:   run,
+	}
+}
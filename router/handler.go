@@ -31,25 +31,87 @@ func (f HandlerFunc) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *h
 	f(ctx, w, r)
 }
 
-// netHTTPWrap is a helper to turn a http.Handler into our Handler
+// ErrorHandler is implemented by handlers that can fail, and that want to
+// control how their own errors are rendered.  Any error returned from
+// ServeHTTPE is passed to the same handler's OnError method.
+//
+// A handler's OnError method always takes precedence over the router-level
+// DefaultOnError function.
+type ErrorHandler interface {
+	ServeHTTPE(context.Context, http.ResponseWriter, *http.Request) error
+	OnError(context.Context, http.ResponseWriter, *http.Request, error)
+}
+
+// ErrorFunc is similar to HandlerFunc, but may return an error.  Since it has
+// no OnError method, any error it returns is passed to DefaultOnError.
+type ErrorFunc func(context.Context, http.ResponseWriter, *http.Request) error
+
+// ServeHTTPE calls f(ctx, w, r).
+func (f ErrorFunc) ServeHTTPE(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return f(ctx, w, r)
+}
+
+// DefaultOnError is called with any error returned from a handler that does
+// not implement ErrorHandler, unless the error is captured by the router (see
+// WithErrorCapture).  By default, it responds with a 500 Internal Server Error
+// containing the error text.
+var DefaultOnError = func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// errorServer is the subset of ErrorHandler that returns errors.
+type errorServer interface {
+	ServeHTTPE(context.Context, http.ResponseWriter, *http.Request) error
+}
+
+// errorWrap is a helper to turn an error-returning handler into our Handler
+type errorWrap struct {
+	h errorServer
+}
+
+func (e errorWrap) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	err := e.h.ServeHTTPE(ctx, w, r)
+	if err == nil {
+		return
+	}
+
+	if eh, ok := e.h.(ErrorHandler); ok {
+		eh.OnError(ctx, w, r, err)
+	} else if h, ok := ctx.Value(errorKey).(*errorHolder); ok {
+		h.err = err
+	} else {
+		DefaultOnError(ctx, w, r, err)
+	}
+}
+
+// netHTTPWrap is a helper to turn a http.Handler into our Handler.  Since the
+// wrapped handler can't be passed our context directly, it is attached to the
+// request instead, so that the handler can use r.Context() to see any values
+// or deadlines set by middleware.
 type netHTTPWrap struct {
 	http.Handler
 }
 
 func (h netHTTPWrap) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	h.ServeHTTP(w, r)
+	h.ServeHTTP(w, r.WithContext(ctx))
 }
 
 // MakeHandler turns a HandlerType into something that implements our Handler
 // interface.  It will panic if the input is not a valid HandlerType.
 func MakeHandler(h types.HandlerType) Handler {
 	switch f := h.(type) {
+	case errorServer:
+		return errorWrap{f}
 	case Handler:
 		return f
 	case http.Handler:
 		return netHTTPWrap{f}
+	case func(context.Context, http.ResponseWriter, *http.Request) error:
+		return errorWrap{ErrorFunc(f)}
 	case func(context.Context, http.ResponseWriter, *http.Request):
 		return HandlerFunc(f)
+	case func(context.Context, *http.Request) (interface{}, error):
+		return errorWrap{ValueFunc(f)}
 	case func(http.ResponseWriter, *http.Request):
 		return netHTTPWrap{http.HandlerFunc(f)}
 	default:
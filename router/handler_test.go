@@ -1,7 +1,9 @@
 package router
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,6 +34,14 @@ func TestMakeHandler(t *testing.T) {
 	fn := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
 	assert.NotNil(t, MakeHandler(fn))
 
+	// Error-returning handler function
+	errFn := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error { return nil }
+	assert.NotNil(t, MakeHandler(errFn))
+
+	// Value-returning handler function
+	valFn := func(ctx context.Context, r *http.Request) (interface{}, error) { return nil, nil }
+	assert.NotNil(t, MakeHandler(valFn))
+
 	// Another, incompatible type
 	assert.Panics(t, func() {
 		MakeHandler(func(i int) int {
@@ -39,3 +49,85 @@ func TestMakeHandler(t *testing.T) {
 		})
 	})
 }
+
+type dummyErrorHandler struct {
+	err error
+}
+
+func (d *dummyErrorHandler) ServeHTTPE(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return errors.New("failed")
+}
+
+func (d *dummyErrorHandler) OnError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	d.err = err
+	w.WriteHeader(http.StatusTeapot)
+}
+
+func TestErrorHandler(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	// The handler's own OnError is called...
+	eh := &dummyErrorHandler{}
+	w := httptest.NewRecorder()
+	MakeHandler(eh).ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.EqualError(t, eh.err, "failed")
+
+	// ... otherwise, we fall back to the default.
+	fn := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errors.New("failed")
+	}
+	w = httptest.NewRecorder()
+	MakeHandler(fn).ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "failed\n", w.Body.String())
+
+	// Successful handlers don't trigger either.
+	fn = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}
+	w = httptest.NewRecorder()
+	MakeHandler(fn).ServeHTTPC(context.Background(), w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMakeHandlerRequestContext(t *testing.T) {
+	t.Parallel()
+
+	// Standard library-style handlers see our context through the request.
+	var val interface{}
+	h := MakeHandler(func(w http.ResponseWriter, r *http.Request) {
+		val = r.Context().Value("key")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	ctx := context.WithValue(context.Background(), "key", "value")
+	h.ServeHTTPC(ctx, httptest.NewRecorder(), r)
+	assert.Equal(t, "value", val)
+}
+
+func TestErrorCapture(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	fn := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errors.New("failed")
+	}
+
+	// Captured errors are stored rather than rendered.
+	ctx := WithErrorCapture(context.Background())
+	w := httptest.NewRecorder()
+	MakeHandler(fn).ServeHTTPC(ctx, w, r)
+	assert.EqualError(t, GetError(ctx), "failed")
+	assert.Equal(t, "", w.Body.String())
+
+	// A handler's own OnError still takes precedence.
+	ctx = WithErrorCapture(context.Background())
+	eh := &dummyErrorHandler{}
+	w = httptest.NewRecorder()
+	MakeHandler(eh).ServeHTTPC(ctx, w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Nil(t, GetError(ctx))
+
+	assert.Nil(t, GetError(context.Background()))
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// methodPattern matches requests with one of a set of methods, as well as an
+// underlying pattern.
+type methodPattern struct {
+	methods []string
+	pat     Pattern
+}
+
+func (p methodPattern) Prefix() string {
+	return p.pat.Prefix()
+}
+
+func (p methodPattern) Match(r *http.Request) bool {
+	return p.MatchContext(r, context.Background())
+}
+
+// MatchContext implements ContextMatcher, passing the context on to the
+// underlying pattern.
+func (p methodPattern) MatchContext(r *http.Request, ctx context.Context) bool {
+	for _, method := range p.methods {
+		if r.Method == method {
+			return matchContext(p.pat, r, ctx)
+		}
+	}
+	return false
+}
+
+func (p methodPattern) Run(r *http.Request, c *context.Context) {
+	p.pat.Run(r, c)
+}
+
+func (p methodPattern) String() string {
+	return fmt.Sprintf("Methods(%v, %q)", p.pat, p.methods)
+}
+
+// Methods returns a Pattern that matches requests that both have one of the
+// given methods, and match the given pattern.  This allows building a router
+// entirely out of patterns, without dispatching on the method separately.
+func Methods(p Pattern, methods ...string) Pattern {
+	return methodPattern{
+		methods: methods,
+		pat:     p,
+	}
+}
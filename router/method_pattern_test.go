@@ -0,0 +1,94 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// patternRouter is a minimal router that is built entirely out of patterns,
+// including the request method.
+type patternRouter struct {
+	patterns []Pattern
+	handlers []Handler
+}
+
+func (p *patternRouter) Handle(pat Pattern, h Handler) {
+	p.patterns = append(p.patterns, pat)
+	p.handlers = append(p.handlers, h)
+}
+
+func (p *patternRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for i, pat := range p.patterns {
+		if pat.Match(r) {
+			ctx := context.Background()
+			pat.Run(r, &ctx)
+			p.handlers[i].ServeHTTPC(ctx, w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func TestMethods(t *testing.T) {
+	t.Parallel()
+
+	p := Methods(ParseStringPattern("/users/:id"), "GET", "HEAD")
+	assert.Equal(t, "/users/", p.Prefix())
+
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/other", false, nil))
+
+	r, _ := http.NewRequest("POST", "/users/123", nil)
+	assert.False(t, p.Match(r))
+}
+
+func TestMethodsContext(t *testing.T) {
+	t.Parallel()
+
+	// The context is passed on to the underlying pattern.
+	p := Methods(ParamConstraint("id", regexp.MustCompile(`^\d+$`)), "GET")
+	cm, ok := p.(ContextMatcher)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	ctx := SetURLParams(context.Background(), map[string]string{"id": "123"})
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	assert.True(t, cm.MatchContext(r, ctx))
+	assert.False(t, p.Match(r))
+
+	r, _ = http.NewRequest("POST", "/users/123", nil)
+	assert.False(t, cm.MatchContext(r, ctx))
+}
+
+func TestPatternRouter(t *testing.T) {
+	t.Parallel()
+
+	respond := func(s string) Handler {
+		return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s + " " + GetURLParams(ctx)["id"]))
+		})
+	}
+
+	pr := &patternRouter{}
+	pr.Handle(Methods(ParseStringPattern("/users/:id"), "GET", "HEAD"), respond("get"))
+	pr.Handle(Methods(ParseStringPattern("/users/:id"), "PUT", "PATCH"), respond("update"))
+
+	send := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, "/users/123", nil)
+		pr.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, "get 123", send("GET").Body.String())
+	assert.Equal(t, "update 123", send("PATCH").Body.String())
+	assert.Equal(t, http.StatusNotFound, send("DELETE").Code)
+}
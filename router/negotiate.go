@@ -0,0 +1,122 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AcceptItem is a single entry from a header that lists values with quality
+// values, such as Accept or Accept-Language (e.g. "fr;q=0.8").
+type AcceptItem struct {
+	// The value, with any parameters removed.
+	Value string
+
+	// The quality value, from 0 to 1.  If the entry doesn't have one, it is
+	// 1.  A quality of 0 means that the value is not acceptable.
+	Q float64
+}
+
+// ParseAcceptHeader parses a comma-separated list of values with optional
+// quality values, as used by the Accept family of headers, in the order in
+// which they appear.  Empty entries are skipped, and malformed quality values
+// are ignored.
+func ParseAcceptHeader(header string) []AcceptItem {
+	var items []AcceptItem
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.TrimSpace(fields[0])
+		if value == "" {
+			continue
+		}
+
+		item := AcceptItem{Value: value, Q: 1}
+		for _, param := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				item.Q = q
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// acceptRange is a single media range from an Accept header.
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges in an Accept header, skipping any that
+// are malformed.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, item := range ParseAcceptHeader(header) {
+		mt := strings.ToLower(item.Value)
+		slash := strings.IndexByte(mt, '/')
+		if slash <= 0 || slash == len(mt)-1 {
+			continue
+		}
+		ranges = append(ranges, acceptRange{typ: mt[:slash], subtype: mt[slash+1:], q: item.Q})
+	}
+	return ranges
+}
+
+// quality returns the quality that the given media ranges assign to a content
+// type, using the most specific range that matches it, and whether any did.
+func quality(ranges []acceptRange, contentType string) (float64, bool) {
+	mt := strings.ToLower(contentType)
+	if i := strings.IndexByte(mt, ';'); i >= 0 {
+		mt = strings.TrimSpace(mt[:i])
+	}
+	typ, subtype, _ := strings.Cut(mt, "/")
+
+	best, q := -1, 0.0
+	for _, ar := range ranges {
+		var specificity int
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			specificity = 2
+		case ar.typ == typ && ar.subtype == "*":
+			specificity = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			specificity = 0
+		default:
+			continue
+		}
+		if specificity > best {
+			best, q = specificity, ar.q
+		}
+	}
+	return q, best >= 0
+}
+
+// Negotiate returns the content type from offers that is most preferred by the
+// request's Accept header, taking quality values and wildcards (e.g. "text/*")
+// into account.  Ties are broken by the order of offers, so the server's
+// preferred types should be listed first.  If the request has no Accept
+// header, the first offer is returned; if none of the offers are acceptable,
+// the empty string is returned.
+func Negotiate(r *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q, ok := quality(ranges, offer); ok && q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	offers := []string{"application/json", "application/xml", "text/plain"}
+
+	var negotiateTests = []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/*", "text/plain"},
+		{"*/*", "application/json"},
+		{"application/xml;q=0.9, application/json;q=0.5", "application/xml"},
+		{"application/*;q=0.5, application/xml", "application/xml"},
+		{"*/*;q=0.1, text/plain", "text/plain"},
+		{"application/json;q=0, */*", "application/xml"},
+		{"image/png", ""},
+		{"bogus, text/plain", "text/plain"},
+	}
+	for _, test := range negotiateTests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		assert.Equal(t, test.expected, Negotiate(r, offers...), test.accept)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	assert.Equal(t, "", Negotiate(r))
+}
+
+func TestParseAcceptHeader(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []AcceptItem{
+		{Value: "fr-CA", Q: 1},
+		{Value: "fr", Q: 0.8},
+		{Value: "*", Q: 0},
+		{Value: "en", Q: 1},
+	}, ParseAcceptHeader("fr-CA, fr;q=0.8, , *;Q=0, en;q=bogus"))
+	assert.Nil(t, ParseAcceptHeader(""))
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"golang.org/x/net/context"
+)
+
+// paramConstraint matches if a previously-bound URL parameter matches a
+// regexp.
+type paramConstraint struct {
+	name string
+	re   *regexp.Regexp
+}
+
+func (p paramConstraint) Prefix() string {
+	return ""
+}
+
+func (p paramConstraint) Match(r *http.Request) bool {
+	// On its own, there are no parameters for us to check.
+	return p.MatchContext(r, context.Background())
+}
+
+func (p paramConstraint) MatchContext(r *http.Request, ctx context.Context) bool {
+	val, ok := GetURLParam(ctx, p.name)
+	return ok && p.re.MatchString(val)
+}
+
+func (p paramConstraint) Run(r *http.Request, c *context.Context) {
+}
+
+func (p paramConstraint) String() string {
+	return fmt.Sprintf("ParamConstraint(%q, %v)", p.name, p.re)
+}
+
+// ParamConstraint returns a Pattern that checks the value of the named URL
+// parameter against the given regexp, which should usually be anchored (e.g.
+// `^\d+$`).  The parameter must be bound by another pattern, so this is only
+// useful when combined with one using And, after the pattern that binds it:
+//
+//	And(ParseStringPattern("/users/:id"), ParamConstraint("id", digits))
+//
+// A request where the parameter wasn't bound never matches.  In particular,
+// the pattern never matches on its own, nor when it comes before the pattern
+// that binds the parameter.  Running it binds nothing.
+func ParamConstraint(name string, re *regexp.Regexp) Pattern {
+	return paramConstraint{
+		name: name,
+		re:   re,
+	}
+}
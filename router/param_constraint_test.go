@@ -0,0 +1,87 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParamConstraint(t *testing.T) {
+	t.Parallel()
+
+	digits := regexp.MustCompile(`^\d+$`)
+	p := And(ParseStringPattern("/users/:id"), ParamConstraint("id", digits))
+	assert.Equal(t, "/users/", p.Prefix())
+
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/users/me", false, nil))
+	runTest(t, p, pt("/posts/123", false, nil))
+
+	// Constraints can be chained, and see the parameters bound by every
+	// earlier pattern.
+	p = And(
+		ParseStringPattern("/users/:id"),
+		ParamConstraint("id", digits),
+		QueryPattern("format", "csv"),
+		ParamConstraint("format", regexp.MustCompile(`^csv$`)),
+	)
+	runTest(t, p, pt("/users/1?format=csv", true, map[string]string{
+		"id":     "1",
+		"format": "csv",
+	}))
+	runTest(t, p, pt("/users/a?format=csv", false, nil))
+
+	// Constraints must come after the pattern that binds the parameter.
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	p = And(ParamConstraint("id", digits), ParseStringPattern("/users/:id"))
+	assert.False(t, p.Match(r))
+	assert.False(t, ParamConstraint("id", digits).Match(r))
+	assert.False(t, And(ParseStringPattern("/users/:name"), ParamConstraint("id", digits)).Match(r))
+
+	// Running a constraint on its own binds nothing.
+	ctx := context.Background()
+	ParamConstraint("id", digits).Run(r, &ctx)
+	assert.Nil(t, GetURLParams(ctx))
+}
+
+func TestParamConstraintCombinators(t *testing.T) {
+	t.Parallel()
+
+	digits := regexp.MustCompile(`^\d+$`)
+
+	// Not negates the constraint, rather than an unbound parameter.
+	p := And(ParseStringPattern("/users/:id"), Not(ParamConstraint("id", digits)))
+	runTest(t, p, pt("/users/123", false, nil))
+	runTest(t, p, pt("/users/me", true, map[string]string{
+		"id": "me",
+	}))
+
+	// Or passes the parameters on to each alternative.
+	p = And(ParseStringPattern("/users/:id"), Or(
+		ParamConstraint("id", digits),
+		ParamConstraint("id", regexp.MustCompile(`^me$`)),
+	))
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"id": "123",
+	}))
+	runTest(t, p, pt("/users/me", true, map[string]string{
+		"id": "me",
+	}))
+	runTest(t, p, pt("/users/bob", false, nil))
+
+	// Nested Ands see the parameters bound outside them.
+	p = And(ParseStringPattern("/users/:id/*"), Not(And(
+		ParseStringPattern("/users/:name/admin"),
+		ParamConstraint("id", digits),
+	)))
+	runTest(t, p, pt("/users/123/admin", false, nil))
+	runTest(t, p, pt("/users/me/admin", true, map[string]string{
+		"id": "me",
+		"*":  "/admin",
+	}))
+}
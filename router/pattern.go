@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sync"
 
 	"golang.org/x/net/context"
 
@@ -37,6 +38,53 @@ type Pattern interface {
 	Run(r *http.Request, ctx *context.Context)
 }
 
+// PathMatcher is implemented by patterns that only examine the request's path,
+// and so can be matched against a bare path without constructing a request.
+// The built-in string and regexp patterns implement this interface.
+type PathMatcher interface {
+	MatchPath(path string) bool
+}
+
+// ContextMatcher is implemented by patterns whose decision depends on the
+// state bound by other patterns, such as ParamConstraint.  When such a pattern
+// is combined with others using And, And calls MatchContext with a context
+// containing everything bound by the patterns before it.  And, Or and Not
+// implement ContextMatcher themselves, passing the context on to the patterns
+// they contain, so that they can be nested (e.g. to negate a constraint).
+type ContextMatcher interface {
+	MatchContext(r *http.Request, ctx context.Context) bool
+}
+
+// SourcePattern is implemented by patterns that were parsed from some source
+// text, such as the built-in string and regexp patterns.  Source returns that
+// text, as given by the user - e.g. "/users/:id" - which is more suitable for
+// logging or comparison than the debugging output of String.
+type SourcePattern interface {
+	Pattern
+	Source() string
+}
+
+// PatternSource returns the source text of the given pattern, if it
+// implements SourcePattern, or its default formatting (as by fmt.Sprint)
+// otherwise.
+func PatternSource(p Pattern) string {
+	if sp, ok := p.(SourcePattern); ok {
+		return sp.Source()
+	}
+	return fmt.Sprint(p)
+}
+
+// usefulPrefix returns the given literal prefix of a pattern, or the empty
+// string if the prefix doesn't narrow down which paths the pattern can match.
+// Since every request path starts with "/", a prefix of "/" (e.g. from the
+// patterns "/", "/:name" or "/*") would only cost time for no benefit.
+func usefulPrefix(prefix string) string {
+	if prefix == "/" {
+		return ""
+	}
+	return prefix
+}
+
 // ParsePattern is used internally by Goji to parse route patterns. It is
 // exposed publicly to make it easier to write thin wrappers around the
 // built-in Pattern implementations.
@@ -52,7 +100,7 @@ func ParsePattern(raw types.PatternType) Pattern {
 	case *regexp.Regexp:
 		return ParseRegexpPattern(v)
 	case string:
-		return ParseStringPattern(v)
+		return parseCachedStringPattern(v)
 	default:
 		msg := fmt.Sprintf(`Unknown pattern type %T. See `+
 			`https://godoc.org/github.com/andrew-d/wolf/types#PatternType `+
@@ -60,3 +108,52 @@ func ParsePattern(raw types.PatternType) Pattern {
 		panic(msg)
 	}
 }
+
+// Cache of parsed string patterns, keyed by the raw pattern.  Since a
+// StringPattern is never modified after it's parsed, it's safe to share one
+// between any number of routes.
+var (
+	patternCacheMu      sync.RWMutex
+	patternCache        = make(map[string]StringPattern)
+	patternCacheEnabled = false
+)
+
+// patternCacheLimit is the maximum number of patterns in the cache.  Once it's
+// full, further patterns are parsed without being cached.
+const patternCacheLimit = 4096
+
+// SetPatternCaching enables or disables the cache that ParsePattern uses for
+// string patterns, which is disabled by default.  When enabled, parsing the
+// same string more than once returns the same parsed pattern, which can speed
+// up building large route tables with many repeated patterns.  At most 4096
+// patterns are cached.  Disabling the cache also empties it.
+func SetPatternCaching(enabled bool) {
+	patternCacheMu.Lock()
+	defer patternCacheMu.Unlock()
+
+	patternCacheEnabled = enabled
+	if !enabled {
+		patternCache = make(map[string]StringPattern)
+	}
+}
+
+func parseCachedStringPattern(raw string) StringPattern {
+	patternCacheMu.RLock()
+	p, ok := patternCache[raw]
+	enabled := patternCacheEnabled
+	patternCacheMu.RUnlock()
+
+	if ok {
+		return p
+	}
+
+	p = ParseStringPattern(raw)
+	if enabled {
+		patternCacheMu.Lock()
+		if patternCacheEnabled && len(patternCache) < patternCacheLimit {
+			patternCache[raw] = p
+		}
+		patternCacheMu.Unlock()
+	}
+	return p
+}
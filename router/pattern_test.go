@@ -1,11 +1,13 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"reflect"
 	"regexp"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 )
 
@@ -82,6 +84,45 @@ var patternTests = []struct {
 			pt("/world/hello", false, nil),
 		}},
 
+	// Regexp tests with greedy tail captures
+	{ParseRegexpPattern(regexp.MustCompile(`^/files/(?P<path>.*)$`)),
+		"/files/", []patternTest{
+			pt("/files/", true, map[string]string{
+				"path": "",
+			}),
+			pt("/files/a/b/c.txt", true, map[string]string{
+				"path": "a/b/c.txt",
+			}),
+			pt("/file", false, nil),
+		}},
+	{ParseRegexpPattern(regexp.MustCompile(`/files/(?P<path>.+)`)),
+		"/files/", []patternTest{
+			pt("/files/a/b", true, map[string]string{
+				"path": "a/b",
+			}),
+			pt("/files/", false, nil),
+			pt("/x/files/a", false, nil),
+		}},
+	{ParseRegexpPattern(regexp.MustCompile(`^/(?P<dir>.*)/(?P<file>[^/]*)$`)),
+		"", []patternTest{
+			pt("/a/b/c", true, map[string]string{
+				"dir":  "a/b",
+				"file": "c",
+			}),
+		}},
+	{ParseRegexpPattern(regexp.MustCompile(`/a/(?P<x>.*)|/b/(?P<y>.*)`)),
+		"", []patternTest{
+			pt("/a/1", true, map[string]string{
+				"x": "1",
+				"y": "",
+			}),
+			pt("/b/2", true, map[string]string{
+				"x": "",
+				"y": "2",
+			}),
+			pt("/c/b/2", false, nil),
+		}},
+
 	// String pattern tests
 	{ParseStringPattern("/hello"),
 		"/hello", []patternTest{
@@ -112,6 +153,57 @@ var patternTests = []struct {
 			pt("/a//b/", false, nil),
 			pt("/a/1/b/2/3", false, nil),
 		}},
+
+	// Empty captures never match, whatever the parameter's position
+	{ParseStringPattern("/:x/b"),
+		"", []patternTest{
+			pt("/a/b", true, map[string]string{
+				"x": "a",
+			}),
+			pt("//b", false, nil),
+		}},
+	{ParseStringPattern("/a/:x/"),
+		"/a/", []patternTest{
+			pt("/a/b/", true, map[string]string{
+				"x": "b",
+			}),
+			pt("/a//", false, nil),
+			pt("/a/", false, nil),
+		}},
+	{ParseStringPattern("/a/:x.json"),
+		"/a/", []patternTest{
+			pt("/a/b.json", true, map[string]string{
+				"x": "b",
+			}),
+			pt("/a/.json", false, nil),
+		}},
+	{ParseStringPattern("/:a.:b/c"),
+		"", []patternTest{
+			pt("/x.y/c", true, map[string]string{
+				"a": "x",
+				"b": "y",
+			}),
+			pt("/.y/c", false, nil),
+			pt("/x./c", false, nil),
+			pt("/./c", false, nil),
+		}},
+	{ParseStringPattern("/:a/:b/*"),
+		"", []patternTest{
+			pt("/x/y/z", true, map[string]string{
+				"a": "x",
+				"b": "y",
+				"*": "/z",
+			}),
+			pt("//y/z", false, nil),
+			pt("/x//z", false, nil),
+		}},
+	{ParseStringPatternOpts("/a/:x/", StringPatternOptions{IgnoreTrailingSlash: true}),
+		"/a/", []patternTest{
+			pt("/a/b", true, map[string]string{
+				"x": "b",
+			}),
+			pt("/a//", false, nil),
+		}},
 	{ParseStringPattern("/a/:b.:c"),
 		"/a/", []patternTest{
 			pt("/a/cat.gif", true, map[string]string{
@@ -128,6 +220,117 @@ var patternTests = []struct {
 			pt("/a/cat.", false, nil),
 			pt("/a/cat/dog.gif", false, nil),
 		}},
+	{ParseStringPattern("/report.:ext"),
+		"/report.", []patternTest{
+			pt("/report.pdf", true, map[string]string{
+				"ext": "pdf",
+			}),
+			pt("/report.tar.gz", true, map[string]string{
+				"ext": "tar.gz",
+			}),
+			pt("/report", false, nil),
+			pt("/report.", false, nil),
+			pt("/report.pdf/", false, nil),
+		}},
+	{ParseStringPattern("/:resource.json"),
+		"", []patternTest{
+			pt("/data.json", true, map[string]string{
+				"resource": "data",
+			}),
+			pt("/my.data.json", true, map[string]string{
+				"resource": "my.data",
+			}),
+			pt("/data.xml", false, nil),
+			pt("/.json", false, nil),
+			pt("/a/b.json", false, nil),
+		}},
+	{ParseStringPattern("/data.json"),
+		"/data.json", []patternTest{
+			pt("/data.json", true, nil),
+			pt("/data.xml", false, nil),
+		}},
+
+	// Patterns whose prefix would be "/" have no prefix, since it would
+	// match every path.
+	{ParseStringPattern("/"),
+		"", []patternTest{
+			pt("/", true, nil),
+			pt("/hello", false, nil),
+		}},
+	{ParseStringPattern("/:x"),
+		"", []patternTest{
+			pt("/hello", true, map[string]string{
+				"x": "hello",
+			}),
+			pt("/", false, nil),
+		}},
+	{ParseStringPattern("/*"),
+		"", []patternTest{
+			pt("/", true, map[string]string{
+				"*": "/",
+			}),
+			pt("/hello/world", true, map[string]string{
+				"*": "/hello/world",
+			}),
+		}},
+	{ParseRegexpPattern(regexp.MustCompile(`^/(?P<x>\w+)$`)),
+		"", []patternTest{
+			pt("/hello", true, map[string]string{
+				"x": "hello",
+			}),
+		}},
+
+	// String pattern option tests
+	{ParseStringPatternOpts("/hello/", StringPatternOptions{IgnoreTrailingSlash: true}),
+		"/hello", []patternTest{
+			pt("/hello", true, nil),
+			pt("/hello/", true, nil),
+			pt("/hello//", false, nil),
+			pt("/hello/world", false, nil),
+		}},
+	{ParseStringPatternOpts("/a/:x", StringPatternOptions{IgnoreTrailingSlash: true}),
+		"/a/", []patternTest{
+			pt("/a/b", true, map[string]string{
+				"x": "b",
+			}),
+			pt("/a/b/", true, map[string]string{
+				"x": "b",
+			}),
+			pt("/a/", false, nil),
+		}},
+	{ParseStringPatternOpts("/", StringPatternOptions{IgnoreTrailingSlash: true}),
+		"", []patternTest{
+			pt("/", true, nil),
+		}},
+	{ParseStringPatternOpts("/Users/:id/Edit", StringPatternOptions{IgnoreCase: true}),
+		"", []patternTest{
+			pt("/users/Bob/edit", true, map[string]string{
+				"id": "Bob",
+			}),
+			pt("/USERS/bob/EDIT", true, map[string]string{
+				"id": "bob",
+			}),
+			pt("/users/bob/delete", false, nil),
+		}},
+	{ParseStringPatternOpts("/files/:name", StringPatternOptions{RawParams: true}),
+		"/files/", []patternTest{
+			pt("/files/a%2Fb", true, map[string]string{
+				"name": "a%2Fb",
+			}),
+			pt("/files/a/b", false, nil),
+		}},
+	{ParseStringPatternOpts("/range/:from-:to", StringPatternOptions{Breaks: "-"}),
+		"/range/", []patternTest{
+			pt("/range/1-10", true, map[string]string{
+				"from": "1",
+				"to":   "10",
+			}),
+			pt("/range/1.5-10", true, map[string]string{
+				"from": "1.5",
+				"to":   "10",
+			}),
+			pt("/range/10", false, nil),
+		}},
 
 	// String prefix tests
 	{ParseStringPattern("/user/:user/*"),
@@ -156,6 +359,36 @@ var patternTests = []struct {
 			}),
 			pt("/user/bob/enemies", false, nil),
 		}},
+	{ParseStringPattern("/files/*path"),
+		"/files/", []patternTest{
+			pt("/files/", true, map[string]string{
+				"path": "/",
+			}),
+			pt("/files/a/b.txt", true, map[string]string{
+				"path": "/a/b.txt",
+			}),
+			pt("/files", false, nil),
+		}},
+	// A final segment with a break character isn't a wildcard.
+	{ParseStringPattern("/files/*.txt"),
+		"/files/*.txt", []patternTest{
+			pt("/files/*.txt", true, nil),
+			pt("/files/a.txt", false, nil),
+			pt("/files/a/b.txt", false, nil),
+		}},
+	{ParseStringPatternOpts("/files/*a-b", StringPatternOptions{Breaks: "-"}),
+		"/files/*a-b", []patternTest{
+			pt("/files/*a-b", true, nil),
+			pt("/files/x", false, nil),
+		}},
+	{ParseStringPattern("/user/:user/*rest"),
+		"/user/", []patternTest{
+			pt("/user/bob/friends/123", true, map[string]string{
+				"user": "bob",
+				"rest": "/friends/123",
+			}),
+			pt("/user/bob", false, nil),
+		}},
 }
 
 func TestPatterns(t *testing.T) {
@@ -188,3 +421,396 @@ func runTest(t *testing.T, p Pattern, test patternTest) {
 		t.Errorf("Expected a context of %v, instead got %v", test.params, got)
 	}
 }
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	var canonicalTests = []struct {
+		pats      []string
+		canonical string
+	}{
+		{[]string{"/hello"}, "/hello"},
+		{[]string{"/users/:id", "/users/:name"}, "/users/:"},
+		{[]string{"//users//:id", "//users//:name"}, "//users//:"},
+		{[]string{"/a/:b.:c", "/a/:x.:y"}, "/a/:.:"},
+		{[]string{"/files/*", "/files/*path"}, "/files/*"},
+		{[]string{"/user/:user/*", "/user/:name/*rest"}, "/user/:/*"},
+	}
+
+	for _, test := range canonicalTests {
+		for _, pat := range test.pats {
+			c := ParseStringPattern(pat).Canonical()
+			if c != test.canonical {
+				t.Errorf("Expected canonical form of %q to be %q, got %q",
+					pat, test.canonical, c)
+			}
+		}
+	}
+
+	// Patterns that match different paths should be different.
+	if ParseStringPattern("/users/:id").Canonical() == ParseStringPattern("/users/*").Canonical() {
+		t.Errorf("Expected different canonical forms for param and wildcard")
+	}
+	if ParseStringPattern("/hello").Canonical() == ParseStringPattern("//hello").Canonical() {
+		t.Errorf("Expected different canonical forms for repeated slashes")
+	}
+
+	// Literal colons and asterisks are escaped, so that they can't be
+	// confused with parameters and wildcards.
+	var escapeTests = []struct {
+		literal, param string
+		canonical      string
+	}{
+		{"/a/:", "/a/:x", `/a/\:`},
+		{"/a:b/:x", "/a/:x", `/a\:b/:`},
+		{"/files/*.txt", "/files/*", `/files/\*.txt`},
+		{`/a\:`, "/a/:x", `/a\\\:`},
+		{"/a [IgnoreCase]", "/a", `/a \[IgnoreCase]`},
+	}
+	for _, test := range escapeTests {
+		c := ParseStringPattern(test.literal).Canonical()
+		assert.Equal(t, test.canonical, c, test.literal)
+		assert.NotEqual(t, ParseStringPattern(test.param).Canonical(), c, test.literal)
+	}
+	assert.NotEqual(t,
+		ParseStringPattern("/a [IgnoreCase]").Canonical(),
+		ParseStringPatternOpts("/a", StringPatternOptions{IgnoreCase: true}).Canonical())
+
+	// Options that change which paths are matched are included.
+	var optionTests = []struct {
+		pat       string
+		opts      StringPatternOptions
+		canonical string
+	}{
+		{"/Users/:id", StringPatternOptions{IgnoreCase: true}, "/users/: [IgnoreCase]"},
+		{"/users/:id/", StringPatternOptions{IgnoreTrailingSlash: true}, "/users/: [IgnoreTrailingSlash]"},
+		{"/files/*", StringPatternOptions{IgnoreTrailingSlash: true}, "/files/*"},
+		{"/:name", StringPatternOptions{RawParams: true, MatrixParams: true}, "/: [RawParams,MatrixParams]"},
+		{"/:a-:b", StringPatternOptions{Breaks: "-"}, "/:-:"},
+	}
+	for _, test := range optionTests {
+		assert.Equal(t, test.canonical, ParseStringPatternOpts(test.pat, test.opts).Canonical(), test.pat)
+	}
+	assert.NotEqual(t,
+		ParseStringPattern("/users/:id").Canonical(),
+		ParseStringPatternOpts("/users/:id", StringPatternOptions{IgnoreTrailingSlash: true}).Canonical())
+}
+
+func TestPatternCache(t *testing.T) {
+	// The cache is off by default.
+	p1 := ParsePattern("/users/:id").(StringPattern)
+	p2 := ParsePattern("/users/:id").(StringPattern)
+	if &p1.literals[0] == &p2.literals[0] {
+		t.Errorf("Expected patterns not to be cached by default")
+	}
+
+	SetPatternCaching(true)
+	defer SetPatternCaching(false)
+
+	// Cached patterns share their underlying storage.
+	p1 = ParsePattern("/users/:id").(StringPattern)
+	p2 = ParsePattern("/users/:id").(StringPattern)
+	if &p1.literals[0] != &p2.literals[0] {
+		t.Errorf("Expected cached patterns to be shared")
+	}
+
+	// The cache doesn't grow without bound.
+	for i := 0; i < 2*patternCacheLimit; i++ {
+		ParsePattern(fmt.Sprintf("/users/%d", i))
+	}
+	patternCacheMu.RLock()
+	n := len(patternCache)
+	patternCacheMu.RUnlock()
+	if n != patternCacheLimit {
+		t.Errorf("Expected the cache to hold %d patterns, got %d", patternCacheLimit, n)
+	}
+}
+
+// A route table with a small number of distinct patterns, repeated many times.
+var benchPatterns = func() []string {
+	var pats []string
+	for i := 0; i < 100; i++ {
+		pats = append(pats,
+			"/users/:id",
+			"/users/:id/friends/*",
+			"/posts/:post/comments/:comment",
+			"/static/*path",
+		)
+	}
+	return pats
+}()
+
+func benchmarkParsePattern(b *testing.B, cache bool) {
+	SetPatternCaching(cache)
+	defer SetPatternCaching(false)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, pat := range benchPatterns {
+			ParsePattern(pat)
+		}
+	}
+}
+
+func BenchmarkParsePatternCached(b *testing.B) {
+	benchmarkParsePattern(b, true)
+}
+
+func BenchmarkParsePatternUncached(b *testing.B) {
+	benchmarkParsePattern(b, false)
+}
+
+func benchmarkSingleParam(b *testing.B, single bool) {
+	p := ParseStringPattern("/users/:id")
+	p.single = single
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := context.Background()
+		p.Run(r, &ctx)
+		if id, _ := GetURLParam(ctx, "id"); id != "123" {
+			b.Fatalf("unexpected id %q", id)
+		}
+	}
+}
+
+func BenchmarkSingleParamFast(b *testing.B) {
+	benchmarkSingleParam(b, true)
+}
+
+func BenchmarkSingleParamGeneral(b *testing.B) {
+	benchmarkSingleParam(b, false)
+}
+
+func TestSingleParam(t *testing.T) {
+	t.Parallel()
+
+	p := ParseStringPattern("/users/:id")
+	assert.True(t, p.single)
+	assert.False(t, ParseStringPattern("/users/:id/*").single)
+	assert.False(t, ParseStringPattern("/users/:id/:action").single)
+
+	// Single parameters can be retrieved either way...
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	ctx := context.Background()
+	p.Run(r, &ctx)
+	id, ok := GetURLParam(ctx, "id")
+	assert.True(t, ok)
+	assert.Equal(t, "123", id)
+	_, ok = GetURLParam(ctx, "name")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"id": "123"}, GetURLParams(ctx))
+
+	// ... and are merged with any existing parameters.
+	ctx = SetURLParams(context.Background(), map[string]string{"org": "acme"})
+	p.Run(r, &ctx)
+	assert.Equal(t, map[string]string{"org": "acme", "id": "123"}, GetURLParams(ctx))
+
+	ctx = context.Background()
+	p.Run(r, &ctx)
+	r, _ = http.NewRequest("GET", "/users/bob/x", nil)
+	ParseStringPattern("/users/:name/*").Run(r, &ctx)
+	assert.Equal(t, map[string]string{"id": "123", "name": "bob", "*": "/x"}, GetURLParams(ctx))
+}
+
+func TestSingleParamAllocs(t *testing.T) {
+	// Looking up a single parameter doesn't allocate a map, including from
+	// within the package's own helpers.  (AllocsPerRun can't be used in a
+	// parallel test.)
+	ctx := context.Background()
+	r, _ := http.NewRequest("GET", "/users/123", nil)
+	ParseStringPattern("/users/:id").Run(r, &ctx)
+	constraint := ParamConstraint("id", regexp.MustCompile(`^\d+$`)).(ContextMatcher)
+	var dest struct {
+		ID string `wolf:"id"`
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		constraint.MatchContext(r, ctx)
+		BindParams(ctx, &dest)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestRegexpRepeatedCapture(t *testing.T) {
+	t.Parallel()
+
+	// Repeated groups bind their last match.
+	re := regexp.MustCompile(`^(?P<seg>/[a-z]+)+$`)
+	runTest(t, ParseRegexpPattern(re), pt("/a/b/c", true, map[string]string{
+		"seg": "/c",
+	}))
+
+	_, err := ParseRegexpPatternStrict(re)
+	assert.Error(t, err)
+
+	// Unnamed groups are reported by number.
+	_, err = ParseRegexpPatternStrict(regexp.MustCompile(`^/x(/(\d+)){2,3}$`))
+	assert.EqualError(t, err, `router: capture group "$1" in regexp ^/x(/(\d+)){2,3}$ is repeated, and will only bind its last match`)
+
+	// Optional and non-repeated groups are fine.
+	for _, s := range []string{
+		`^/files/(?P<path>.*)$`,
+		`^/a(?P<x>/b)?$`,
+		`^/(?:[a-z]+)+/(?P<id>\d+)$`,
+		`^/(?P<x>a){1}$`,
+	} {
+		p, err := ParseRegexpPatternStrict(regexp.MustCompile(s))
+		assert.NoError(t, err, s)
+		assert.Equal(t, ParseRegexpPattern(regexp.MustCompile(s)), p, s)
+	}
+}
+
+func TestRegexpPatternSegmented(t *testing.T) {
+	t.Parallel()
+
+	p := ParseRegexpPatternSegmented(regexp.MustCompile(`^/user`))
+	assert.Equal(t, "/user", p.Prefix())
+	assert.Equal(t, "^/user", p.Source())
+	runTest(t, p, pt("/user", true, nil))
+	runTest(t, p, pt("/user/bob", true, nil))
+	runTest(t, p, pt("/username", false, nil))
+
+	// Capture groups are preserved, and the regexp is still left-anchored.
+	p = ParseRegexpPatternSegmented(regexp.MustCompile(`/u/(?P<name>[a-z]+)|/users/(\d+)`))
+	runTest(t, p, pt("/u/bob/posts", true, map[string]string{
+		"name": "bob",
+		"$2":   "",
+	}))
+	runTest(t, p, pt("/users/123", true, map[string]string{
+		"name": "",
+		"$2":   "123",
+	}))
+	runTest(t, p, pt("/u/bob2", false, nil))
+	runTest(t, p, pt("/x/u/bob", false, nil))
+
+	// Alternatives that match a shorter prefix don't prevent a match.
+	p = ParseRegexpPatternSegmented(regexp.MustCompile(`^/(?P<name>user|username)`))
+	runTest(t, p, pt("/username/x", true, map[string]string{
+		"name": "username",
+	}))
+
+	// A regexp ending in a slash is already at a segment boundary.
+	p = ParseRegexpPatternSegmented(regexp.MustCompile(`^/user/`))
+	runTest(t, p, pt("/user/bob", true, nil))
+	runTest(t, p, pt("/user", false, nil))
+}
+
+func TestMatchPath(t *testing.T) {
+	t.Parallel()
+
+	// MatchPath agrees with Match for every built-in pattern test.
+	for _, test := range patternTests {
+		pm, ok := test.pat.(PathMatcher)
+		if !assert.True(t, ok, "%v should implement PathMatcher", test.pat) {
+			continue
+		}
+
+		for _, pt := range test.tests {
+			path := pt.r.URL.Path
+			if sp, ok := test.pat.(StringPattern); ok && sp.opts.RawParams {
+				path = pt.r.URL.EscapedPath()
+			}
+			assert.Equal(t, pt.match, pm.MatchPath(path), "%v: %q", test.pat, path)
+		}
+	}
+}
+
+func TestMatrixParams(t *testing.T) {
+	t.Parallel()
+
+	p := ParseStringPatternOpts("/cars/:id/wheels/:n", StringPatternOptions{MatrixParams: true})
+
+	var matrixTests = []struct {
+		url    string
+		match  bool
+		params map[string]string
+		matrix map[string]string
+	}{
+		{"/cars/42/wheels/4", true, map[string]string{"id": "42", "n": "4"}, nil},
+		{"/cars/42;color=red;doors=4/wheels/1;new", true,
+			map[string]string{"id": "42", "n": "1"},
+			map[string]string{"color": "red", "doors": "4", "new": ""}},
+		{"/cars/;color=red/wheels/4", false, nil, nil},
+	}
+
+	for _, test := range matrixTests {
+		r, _ := http.NewRequest("GET", test.url, nil)
+		ctx := context.Background()
+		assert.Equal(t, test.match, p.Match(r), test.url)
+		if !test.match {
+			continue
+		}
+
+		p.Run(r, &ctx)
+		assert.Equal(t, test.params, GetURLParams(ctx), test.url)
+		assert.Equal(t, test.matrix, GetMatrixParams(ctx), test.url)
+	}
+
+	// Without the option, semicolons are part of the value.
+	runTest(t, ParsePattern("/cars/:id"), pt("/cars/42;color=red", true, map[string]string{
+		"id": "42;color=red",
+	}))
+}
+
+func TestPatternIntrospection(t *testing.T) {
+	t.Parallel()
+
+	var stringTests = []struct {
+		pat      string
+		wildcard bool
+		static   bool
+	}{
+		{"/", false, true},
+		{"/users/me", false, true},
+		{"/users/:id", false, false},
+		{"/users/*", true, false},
+		{"/users/:id/*", true, false},
+	}
+	for _, test := range stringTests {
+		p := ParseStringPattern(test.pat)
+		assert.Equal(t, test.wildcard, p.HasWildcard(), test.pat)
+		assert.Equal(t, test.static, p.IsStatic(), test.pat)
+	}
+
+	assert.True(t, ParseRegexpPattern(regexp.MustCompile(`^/(?P<id>\d+)$`)).HasNamedGroups())
+	assert.False(t, ParseRegexpPattern(regexp.MustCompile(`^/(\d+)$`)).HasNamedGroups())
+	assert.False(t, ParseRegexpPattern(regexp.MustCompile(`^/users$`)).HasNamedGroups())
+}
+
+func TestParamNamesAndTemplate(t *testing.T) {
+	t.Parallel()
+
+	var templateTests = []struct {
+		pat      string
+		names    []string
+		template string
+	}{
+		{"/", []string{}, "/"},
+		{"/users/:id", []string{"id"}, "/users/{id}"},
+		{"/users/:id/files/*path", []string{"id", "path"}, "/users/{id}/files/{path}"},
+		{"/files/*", []string{"*"}, "/files/{*}"},
+		{"/:name.:ext", []string{"name", "ext"}, "/{name}.{ext}"},
+	}
+	for _, test := range templateTests {
+		p := ParseStringPattern(test.pat)
+		assert.Equal(t, test.names, p.ParamNames(), test.pat)
+		assert.Equal(t, test.template, p.Template(), test.pat)
+	}
+}
+
+func TestPatternSource(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/users/:id", PatternSource(ParsePattern("/users/:id")))
+	assert.Equal(t, "/files/*path", PatternSource(ParseStringPatternOpts("/files/*path", StringPatternOptions{
+		IgnoreTrailingSlash: true,
+	})))
+	assert.Equal(t, `^/users/(?P<id>\d+)$`, PatternSource(ParsePattern(regexp.MustCompile(`^/users/(?P<id>\d+)$`))))
+	assert.Equal(t, `/a|/b`, PatternSource(ParsePattern(regexp.MustCompile(`/a|/b`))))
+
+	// Other patterns fall back to their default formatting.
+	p := QueryPattern("format", "csv")
+	assert.Equal(t, `QueryPattern("format", "csv")`, PatternSource(p))
+}
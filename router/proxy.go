@@ -0,0 +1,82 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"golang.org/x/net/context"
+)
+
+// ProxyHandler is a Handler that forwards requests to an upstream server.  It
+// is created by ReverseProxy.
+type ProxyHandler struct {
+	// Param is the name of the URL parameter that holds the path to forward
+	// to the upstream server.  If empty, the wildcard parameter "*" is used.
+	// If the parameter isn't set for a request, the request's path is
+	// forwarded unchanged.
+	Param string
+
+	// ModifyRequest, if non-nil, is called with each outbound request after
+	// it has been rewritten to point at the upstream server.
+	ModifyRequest func(context.Context, *http.Request)
+
+	// ModifyResponse, if non-nil, is called with each response from the
+	// upstream server before it is copied to the client.  If it returns an
+	// error, the client is sent a 502 Bad Gateway instead.
+	ModifyResponse func(*http.Response) error
+
+	proxy *httputil.ReverseProxy
+}
+
+// ReverseProxy returns a Handler that proxies requests to the given target,
+// using httputil.NewSingleHostReverseProxy.  The path of the outbound request
+// is the target's path joined with the matched wildcard, so that a handler
+// mounted at "/proxy/*" will forward a request for "/proxy/foo/bar" to
+// "/foo/bar" on the target.
+func ReverseProxy(target *url.URL) *ProxyHandler {
+	return &ProxyHandler{
+		proxy: httputil.NewSingleHostReverseProxy(target),
+	}
+}
+
+// ServeHTTPC implements Handler.
+func (p *ProxyHandler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	name := p.Param
+	if name == "" {
+		name = "*"
+	}
+
+	// Make a shallow copy of the request (and its URL) so that we don't modify
+	// the request seen by any middleware.
+	out := new(http.Request)
+	*out = *r
+	u := new(url.URL)
+	*u = *r.URL
+	out.URL = u
+
+	if tail, ok := GetURLParam(ctx, name); ok {
+		out.URL.Path = tail
+		out.URL.RawPath = ""
+	}
+
+	// The underlying proxy is shared between requests, so we can't set
+	// per-request hooks on it; instead, we use a copy that has them.
+	proxy := *p.proxy
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		if p.ModifyRequest != nil {
+			p.ModifyRequest(ctx, req)
+		}
+	}
+	proxy.ModifyResponse = p.ModifyResponse
+
+	proxy.ServeHTTP(w, out)
+}
+
+// ServeHTTP implements http.Handler.  Since no URL parameters are available,
+// the request's path is forwarded unchanged.
+func (p *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.ServeHTTPC(context.Background(), w, r)
+}
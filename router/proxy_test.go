@@ -0,0 +1,95 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func newUpstream(t *testing.T) (*httptest.Server, *url.URL) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Header", r.Header.Get("X-Proxy-Header"))
+		io.WriteString(w, r.URL.RequestURI())
+	}))
+
+	u, err := url.Parse(srv.URL + "/base")
+	assert.NoError(t, err)
+	return srv, u
+}
+
+func proxyRequest(h *ProxyHandler, pattern, path string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", path, nil)
+	ctx := context.Background()
+	ParsePattern(pattern).Run(r, &ctx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(ctx, w, r)
+	return w
+}
+
+func TestReverseProxy(t *testing.T) {
+	t.Parallel()
+
+	srv, target := newUpstream(t)
+	defer srv.Close()
+
+	h := ReverseProxy(target)
+
+	w := proxyRequest(h, "/proxy/*", "/proxy/foo/bar?q=1")
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/base/foo/bar?q=1", string(body))
+
+	// Without a wildcard, the whole path is forwarded.
+	w = proxyRequest(h, "/foo", "/foo")
+	body, _ = ioutil.ReadAll(w.Body)
+	assert.Equal(t, "/base/foo", string(body))
+}
+
+func TestReverseProxyParam(t *testing.T) {
+	t.Parallel()
+
+	srv, target := newUpstream(t)
+	defer srv.Close()
+
+	h := ReverseProxy(target)
+	h.Param = "rest"
+
+	w := proxyRequest(h, "/proxy/*rest", "/proxy/a/b")
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, "/base/a/b", string(body))
+}
+
+func TestReverseProxyHooks(t *testing.T) {
+	t.Parallel()
+
+	srv, target := newUpstream(t)
+	defer srv.Close()
+
+	h := ReverseProxy(target)
+	h.ModifyRequest = func(ctx context.Context, r *http.Request) {
+		r.Header.Set("X-Proxy-Header", "from-proxy")
+	}
+	h.ModifyResponse = func(resp *http.Response) error {
+		resp.Header.Set("X-Modified", "yes")
+		return nil
+	}
+
+	w := proxyRequest(h, "/proxy/*", "/proxy/x")
+	assert.Equal(t, "from-proxy", w.Header().Get("X-Upstream-Header"))
+	assert.Equal(t, "yes", w.Header().Get("X-Modified"))
+
+	// Errors from ModifyResponse become a bad gateway.
+	h.ModifyResponse = func(resp *http.Response) error {
+		return errors.New("bad response")
+	}
+	w = proxyRequest(h, "/proxy/*", "/proxy/x")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// unwrapper is implemented by ResponseWriters that wrap another writer (e.g.
+// those created by middleware), and allows retrieving the wrapped writer.
+type unwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// findPusher looks for a http.Pusher in the given writer or any of the
+// writers that it wraps.
+func findPusher(w http.ResponseWriter) (http.Pusher, bool) {
+	for {
+		if p, ok := w.(http.Pusher); ok {
+			return p, true
+		}
+
+		u, ok := w.(unwrapper)
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// Push initiates a HTTP/2 server push of the given target, as described by
+// http.Pusher.  Since middleware may wrap the ResponseWriter, Push will look
+// through any writers with an Unwrap() http.ResponseWriter method to find one
+// that supports pushing.  If none does (e.g. because the request is not using
+// HTTP/2), http.ErrNotSupported is returned.
+func Push(ctx context.Context, w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p, ok := findPusher(w)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type fakePusher struct {
+	http.ResponseWriter
+	pushed []string
+}
+
+func (f *fakePusher) Push(target string, opts *http.PushOptions) error {
+	f.pushed = append(f.pushed, target)
+	return nil
+}
+
+type wrappedWriter struct {
+	http.ResponseWriter
+}
+
+func (w wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// Plain writers don't support pushing.
+	err := Push(ctx, httptest.NewRecorder(), "/style.css", nil)
+	assert.Equal(t, http.ErrNotSupported, err)
+
+	// Pushers are found, even when wrapped.
+	p := &fakePusher{ResponseWriter: httptest.NewRecorder()}
+	assert.NoError(t, Push(ctx, p, "/style.css", nil))
+	assert.NoError(t, Push(ctx, wrappedWriter{wrappedWriter{p}}, "/app.js", nil))
+	assert.Equal(t, []string{"/style.css", "/app.js"}, p.pushed)
+}
+
+func TestPushHTTP2(t *testing.T) {
+	t.Parallel()
+
+	var found bool
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, found = findPusher(wrappedWriter{w})
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	resp, err := s.Client().Get(s.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.True(t, found)
+	}
+}
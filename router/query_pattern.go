@@ -0,0 +1,49 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// queryPattern matches requests with a given query parameter value.
+type queryPattern struct {
+	key   string
+	value string
+}
+
+func (p queryPattern) Prefix() string {
+	return ""
+}
+
+func (p queryPattern) Match(r *http.Request) bool {
+	return r.URL.Query().Get(p.key) == p.value
+}
+
+func (p queryPattern) Run(r *http.Request, c *context.Context) {
+	if !p.Match(r) {
+		return
+	}
+	*c = MergeURLParams(*c, map[string]string{p.key: p.value})
+}
+
+func (p queryPattern) String() string {
+	return fmt.Sprintf("QueryPattern(%q, %q)", p.key, p.value)
+}
+
+// QueryPattern returns a Pattern that matches requests where the first value
+// of the given query parameter is equal to value.  It is mostly useful when
+// combined with a path pattern using And, so that requests for the same path
+// can be routed to different handlers based on the query string - e.g.
+// "/report?format=csv" and "/report?format=pdf".
+//
+// Running the pattern binds the value as a URL parameter with the same name as
+// the query parameter.  Note that an empty value matches requests where the
+// query parameter is missing, as well as those where it is empty.
+func QueryPattern(key, value string) Pattern {
+	return queryPattern{
+		key:   key,
+		value: value,
+	}
+}
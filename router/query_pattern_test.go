@@ -0,0 +1,36 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestQueryPattern(t *testing.T) {
+	t.Parallel()
+
+	p := QueryPattern("format", "csv")
+	assert.Equal(t, "", p.Prefix())
+
+	runTest(t, p, pt("/report?format=csv", true, map[string]string{
+		"format": "csv",
+	}))
+	runTest(t, p, pt("/report?format=pdf", false, nil))
+	runTest(t, p, pt("/report", false, nil))
+
+	// Combined with a path pattern, parameters from both are bound.
+	p = And(ParseStringPattern("/reports/:id"), QueryPattern("format", "csv"))
+	runTest(t, p, pt("/reports/1?format=csv", true, map[string]string{
+		"id":     "1",
+		"format": "csv",
+	}))
+	runTest(t, p, pt("/reports/1?format=pdf", false, nil))
+
+	// Running a non-matching pattern doesn't change the context.
+	r, _ := http.NewRequest("GET", "/?format=pdf", nil)
+	ctx := context.Background()
+	QueryPattern("format", "csv").Run(r, &ctx)
+	assert.Nil(t, GetURLParams(ctx))
+}
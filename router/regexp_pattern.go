@@ -7,19 +7,29 @@ import (
 	"net/http"
 	"regexp"
 	"regexp/syntax"
+	"strings"
 
 	"golang.org/x/net/context"
 )
 
 // RegexpPattern represents a Pattern obtained from a regexp.
+//
+// Each capture group in the regexp is bound to a URL parameter (see
+// PatternType).  Since Go's regexp package only records the last match of a
+// capture group that is repeated - e.g. the group in "(?P<seg>/[a-z]+)+" - the
+// parameter for such a group is bound to the last thing it matched; given the
+// path "/a/b/c", "seg" will be "/c".  This is rarely what was intended, so
+// ParseRegexpPattern warns about such groups, and ParseRegexpPatternStrict
+// returns an error.
 type RegexpPattern struct {
 	re     *regexp.Regexp
+	src    string // Source of the regexp, before anchoring
 	prefix string
 	names  []string
 }
 
 func (p RegexpPattern) Prefix() string {
-	return p.prefix
+	return usefulPrefix(p.prefix)
 }
 
 func (p RegexpPattern) Match(r *http.Request) bool {
@@ -30,6 +40,22 @@ func (p RegexpPattern) Run(r *http.Request, c *context.Context) {
 	p.match(r, c, false)
 }
 
+// MatchPath implements PathMatcher.
+func (p RegexpPattern) MatchPath(path string) bool {
+	return p.re.MatchString(path)
+}
+
+// HasNamedGroups returns whether the regexp contains any named capture groups,
+// and thus binds any parameters other than the positional "$N" ones.
+func (p RegexpPattern) HasNamedGroups() bool {
+	for _, name := range p.re.SubexpNames()[1:] {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (p RegexpPattern) match(r *http.Request, c *context.Context, dryrun bool) bool {
 	matches := p.re.FindStringSubmatch(r.URL.Path)
 	if matches == nil || len(matches) == 0 {
@@ -49,10 +75,17 @@ func (p RegexpPattern) match(r *http.Request, c *context.Context, dryrun bool) b
 		params[p.names[i]] = matches[i]
 	}
 
-	*c = SetURLParams(*c, params)
+	*c = MergeURLParams(*c, params)
+	*c = context.WithValue(*c, positionalParamKey, matches[1:])
 	return true
 }
 
+// Source implements SourcePattern, returning the source of the regexp that
+// the pattern was parsed from.
+func (p RegexpPattern) Source() string {
+	return p.src
+}
+
 func (p RegexpPattern) String() string {
 	return fmt.Sprintf("RegexpPattern(%v)", p.re)
 }
@@ -98,8 +131,10 @@ func sketchOnRegex(re *regexp.Regexp) (*regexp.Regexp, string) {
 
 	// If it's not left-anchored, we add that now.
 	if p.StartCond()&syntax.EmptyBeginText == 0 {
-		// I hope doing this is always legal...
-		newRe, err := regexp.Compile(`\A` + rawRe)
+		// The original is wrapped in a non-capturing group, so that the
+		// anchor applies to every branch of a top-level alternation, and
+		// the numbering of capture groups is unchanged.
+		newRe, err := regexp.Compile(`\A(?:` + rawRe + `)`)
 		if err != nil {
 			// TODO: better way to warn?
 			log.Printf("WARN(router): unable to create a left-"+
@@ -162,9 +197,92 @@ OuterLoop:
 	return re, buf.String()
 }
 
+// repeatedCapture returns the name (or number, if unnamed) of the first
+// capture group in the given regexp that is inside a repetition, or false if
+// there is none.
+func repeatedCapture(re *syntax.Regexp, repeated bool) (string, bool) {
+	switch re.Op {
+	case syntax.OpCapture:
+		if repeated {
+			if re.Name != "" {
+				return re.Name, true
+			}
+			return fmt.Sprintf("$%d", re.Cap), true
+		}
+	case syntax.OpStar, syntax.OpPlus:
+		repeated = true
+	case syntax.OpRepeat:
+		if re.Max == -1 || re.Max > 1 {
+			repeated = true
+		}
+	}
+
+	for _, sub := range re.Sub {
+		if name, ok := repeatedCapture(sub, repeated); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// checkRepeatedCapture returns an error if the given regexp contains a capture
+// group that is repeated.
+func checkRepeatedCapture(re *regexp.Regexp) error {
+	sRe, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return err
+	}
+
+	if name, ok := repeatedCapture(sRe, false); ok {
+		return fmt.Errorf("router: capture group %q in regexp %v is repeated, "+
+			"and will only bind its last match", name, re)
+	}
+	return nil
+}
+
+// ParseRegexpPatternStrict is like ParseRegexpPattern, but returns an error
+// instead of a warning if the regexp contains a repeated capture group.
+func ParseRegexpPatternStrict(re *regexp.Regexp) (RegexpPattern, error) {
+	if err := checkRepeatedCapture(re); err != nil {
+		return RegexpPattern{}, err
+	}
+	return parseRegexpPattern(re), nil
+}
+
 // ParseRegexpPattern will turn the given Regexp into something that implements
-// Pattern, possibly modifying it such that it is left-anchored.
+// Pattern, possibly modifying it such that it is left-anchored.  A warning is
+// logged if the regexp contains a repeated capture group (see RegexpPattern).
 func ParseRegexpPattern(re *regexp.Regexp) RegexpPattern {
+	if err := checkRepeatedCapture(re); err != nil {
+		// TODO: better way to warn?
+		log.Printf("WARN(router): %v. This route might behave unexpectedly.", err)
+	}
+	return parseRegexpPattern(re)
+}
+
+// ParseRegexpPatternSegmented is like ParseRegexpPattern, but the regexp must
+// match up to the end of a path segment - i.e. the end of the path, or a
+// slash.  This gives prefix-style regexps the same semantics as string
+// patterns, so that "^/user" matches "/user" and "/user/bob", but not
+// "/username".
+//
+// This is done by appending "(?:/|$)" to the regexp, so capture groups and
+// left-anchoring are unaffected.  A regexp that already ends with a slash
+// always matches up to a segment boundary, and so is used unchanged.  The
+// pattern's Source is the original regexp.
+func ParseRegexpPatternSegmented(re *regexp.Regexp) RegexpPattern {
+	src := re.String()
+	if strings.HasSuffix(src, "/") {
+		return ParseRegexpPattern(re)
+	}
+
+	p := ParseRegexpPattern(regexp.MustCompile(`(?:` + src + `)(?:/|$)`))
+	p.src = src
+	return p
+}
+
+func parseRegexpPattern(re *regexp.Regexp) RegexpPattern {
+	src := re.String()
 	re, prefix := sketchOnRegex(re)
 	rnames := re.SubexpNames()
 
@@ -182,6 +300,7 @@ func ParseRegexpPattern(re *regexp.Regexp) RegexpPattern {
 
 	return RegexpPattern{
 		re:     re,
+		src:    src,
 		prefix: prefix,
 		names:  names,
 	}
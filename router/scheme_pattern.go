@@ -0,0 +1,68 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// schemePattern matches requests made with a given URL scheme.
+type schemePattern struct {
+	scheme         string
+	trustForwarded bool
+}
+
+func (p schemePattern) Prefix() string {
+	return ""
+}
+
+func (p schemePattern) Match(r *http.Request) bool {
+	return strings.EqualFold(requestScheme(r, p.trustForwarded), p.scheme)
+}
+
+func (p schemePattern) Run(r *http.Request, c *context.Context) {
+}
+
+func (p schemePattern) String() string {
+	return fmt.Sprintf("SchemePattern(%q)", p.scheme)
+}
+
+// requestScheme determines the scheme that a request was made with.
+func requestScheme(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			// Multiple proxies may each have appended a value; the first
+			// one is the scheme that the client used.
+			if i := strings.IndexByte(proto, ','); i >= 0 {
+				proto = proto[:i]
+			}
+			return strings.TrimSpace(proto)
+		}
+	}
+
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// SchemePattern returns a Pattern that matches requests made with the given
+// URL scheme (e.g. "https"), compared case-insensitively.  The scheme of a
+// request is taken from the request URL if present, and otherwise determined
+// by whether the request was made over TLS.
+//
+// If trustForwarded is true, the X-Forwarded-Proto header takes precedence
+// over the request itself.  This should only be enabled when the server is
+// behind a proxy that sets this header, since otherwise any client can claim
+// to be using any scheme.
+func SchemePattern(scheme string, trustForwarded bool) Pattern {
+	return schemePattern{
+		scheme:         scheme,
+		trustForwarded: trustForwarded,
+	}
+}
@@ -0,0 +1,43 @@
+package router
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemePattern(t *testing.T) {
+	t.Parallel()
+
+	direct := SchemePattern("https", false)
+	proxied := SchemePattern("https", true)
+	assert.Equal(t, "", direct.Prefix())
+
+	// Plain HTTP request
+	r, _ := http.NewRequest("GET", "/", nil)
+	assert.False(t, direct.Match(r))
+	assert.False(t, proxied.Match(r))
+
+	// Direct TLS request
+	r.TLS = &tls.ConnectionState{}
+	assert.True(t, direct.Match(r))
+	assert.True(t, proxied.Match(r))
+
+	// Proxied requests are only trusted when configured.
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Proto", "HTTPS, http")
+	assert.False(t, direct.Match(r))
+	assert.True(t, proxied.Match(r))
+
+	// The forwarded header can also downgrade a request.
+	r.TLS = &tls.ConnectionState{}
+	r.Header.Set("X-Forwarded-Proto", "http")
+	assert.True(t, direct.Match(r))
+	assert.False(t, proxied.Match(r))
+
+	// Absolute request URLs carry their own scheme.
+	r, _ = http.NewRequest("GET", "https://example.com/", nil)
+	assert.True(t, direct.Match(r))
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// segmentCountPattern matches paths with a given number of segments.
+type segmentCountPattern int
+
+func (p segmentCountPattern) Prefix() string {
+	return ""
+}
+
+func (p segmentCountPattern) Match(r *http.Request) bool {
+	return segmentCount(r.URL.Path) == int(p)
+}
+
+func (p segmentCountPattern) Run(r *http.Request, c *context.Context) {
+}
+
+func (p segmentCountPattern) String() string {
+	return fmt.Sprintf("SegmentCountPattern(%d)", int(p))
+}
+
+// segmentCount returns the number of slash-separated segments in a path.
+func segmentCount(path string) int {
+	if path == "/" || path == "" {
+		return 0
+	}
+	return strings.Count(path, "/")
+}
+
+// SegmentCountPattern returns a Pattern that matches requests whose path has
+// exactly n segments, regardless of their content - e.g. "/a/b/c" has three.
+// The path "/" has no segments, and a trailing slash begins a new (empty)
+// segment, so "/a/b/" also has three.
+//
+// It is mostly useful when combined with other patterns using And, e.g. to
+// limit how deep a wildcard can match:
+//
+//	And(ParsePattern("/files/*"), SegmentCountPattern(3))
+func SegmentCountPattern(n int) Pattern {
+	return segmentCountPattern(n)
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentCountPattern(t *testing.T) {
+	t.Parallel()
+
+	p := SegmentCountPattern(3)
+	assert.Equal(t, "", p.Prefix())
+	runTest(t, p, pt("/a/b/c", true, nil))
+	runTest(t, p, pt("/a/b/", true, nil))
+	runTest(t, p, pt("/a/b", false, nil))
+	runTest(t, p, pt("/a/b/c/d", false, nil))
+
+	runTest(t, SegmentCountPattern(0), pt("/", true, nil))
+	runTest(t, SegmentCountPattern(1), pt("/a", true, nil))
+
+	// Bounding the depth of a wildcard.
+	p = And(ParsePattern("/files/*"), SegmentCountPattern(3))
+	runTest(t, p, pt("/files/a/b", true, map[string]string{
+		"*": "/a/b",
+	}))
+	runTest(t, p, pt("/files/a", false, nil))
+	runTest(t, p, pt("/files/a/b/c", false, nil))
+	runTest(t, p, pt("/other/a/b", false, nil))
+}
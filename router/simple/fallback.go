@@ -0,0 +1,185 @@
+package simple
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+)
+
+// FallbackConfig configures the behaviour of a fallback handler.
+type FallbackConfig struct {
+	// OnlyNotFound, if true, causes the request to fall through to the
+	// secondary handler only when the primary responds with 404 Not Found.
+	// By default, 405 Method Not Allowed responses also fall through.
+	OnlyNotFound bool
+}
+
+// Fallback returns a handler that serves each request with primary and, if it
+// responds with 404 Not Found or 405 Method Not Allowed (e.g. because none of
+// a router's routes matched), serves the request with secondary instead.  This
+// allows layering independently-built routers (e.g. an API router, and another
+// for static assets) without merging their route tables.
+//
+// To decide whether to fall through, the primary's response is held back until
+// its status is known: headers set by the primary are buffered until it writes
+// its status, and if the request falls through, the primary's body is
+// discarded.  Responses that don't fall through are passed on to the client as
+// they are written, so only the headers are buffered.  Similarly, whatever part
+// of the request body the primary reads before its status is known (or after
+// it has decided to fall through) is buffered, so that the secondary can read
+// the whole body again.  A primary that reads a large body before writing its
+// status therefore holds all of it in memory; once it has written a status
+// that doesn't fall through, the buffer is dropped and the rest of the body is
+// no longer recorded.  A request whose connection is hijacked by the primary
+// never falls through.
+func Fallback(primary, secondary http.Handler) http.Handler {
+	return FallbackWithConfig(primary, secondary, FallbackConfig{})
+}
+
+// FallbackWithConfig is like Fallback, but with the given configuration.
+func FallbackWithConfig(primary, secondary http.Handler, config FallbackConfig) http.Handler {
+	fallsThrough := func(code int) bool {
+		if config.OnlyNotFound {
+			return code == http.StatusNotFound
+		}
+		return code == http.StatusNotFound || code == http.StatusMethodNotAllowed
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fw := &fallbackWriter{
+			w:            w,
+			header:       make(http.Header),
+			fallsThrough: fallsThrough,
+		}
+
+		// Record what the primary reads of the body, so that we can replay
+		// it to the secondary.
+		var rb *replayBody
+		pr := r
+		if r.Body != nil && r.Body != http.NoBody {
+			rb = &replayBody{body: r.Body, fw: fw}
+			pr = new(http.Request)
+			*pr = *r
+			pr.Body = rb
+		}
+		primary.ServeHTTP(fw, pr)
+
+		if fw.fellThrough {
+			if rb != nil && rb.buf.Len() > 0 {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(&rb.buf, r.Body), r.Body}
+				r = r2
+			}
+			secondary.ServeHTTP(w, r)
+		} else if !fw.wroteHeader {
+			// The primary didn't write anything, so we send the implicit
+			// 200 OK (along with any headers it set).
+			fw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// replayBody records what is read from a request body, for as long as the
+// request may still fall through.  Closing it does nothing, so that the rest of
+// the body can still be read afterwards.
+type replayBody struct {
+	body io.ReadCloser
+	fw   *fallbackWriter
+	buf  bytes.Buffer
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.fw.wroteHeader && !b.fw.fellThrough {
+		// The response has been committed, so the body will never be
+		// replayed.
+		b.buf = bytes.Buffer{}
+	} else {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *replayBody) Close() error {
+	return nil
+}
+
+// fallbackWriter holds back a response until its status is known, and
+// discards it if it should fall through to another handler.
+type fallbackWriter struct {
+	w            http.ResponseWriter
+	header       http.Header
+	fallsThrough func(int) bool
+
+	wroteHeader bool
+	fellThrough bool
+}
+
+func (f *fallbackWriter) Header() http.Header {
+	return f.header
+}
+
+func (f *fallbackWriter) WriteHeader(code int) {
+	if f.wroteHeader {
+		return
+	}
+	f.wroteHeader = true
+
+	if f.fallsThrough(code) {
+		f.fellThrough = true
+		return
+	}
+
+	h := f.w.Header()
+	for k, v := range f.header {
+		h[k] = v
+	}
+	f.w.WriteHeader(code)
+}
+
+func (f *fallbackWriter) Write(b []byte) (int, error) {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	if f.fellThrough {
+		return len(b), nil
+	}
+	return f.w.Write(b)
+}
+
+// Flush implements http.Flusher, if the underlying writer does.  Responses
+// that fall through are never flushed.
+func (f *fallbackWriter) Flush() {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	if fl, ok := f.w.(http.Flusher); ok && !f.fellThrough {
+		fl.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if the underlying writer does.  Once the
+// connection has been hijacked, the request can no longer fall through.
+func (f *fallbackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := f.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		f.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (f *fallbackWriter) Unwrap() http.ResponseWriter {
+	return f.w
+}
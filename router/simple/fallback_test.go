@@ -0,0 +1,163 @@
+package simple
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/andrew-d/wolf/builder"
+)
+
+func TestFallback(t *testing.T) {
+	t.Parallel()
+
+	api := builder.New()
+	api.Get("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-From", "api")
+		w.Write([]byte("users"))
+	})
+	api.Post("/static/upload", func(w http.ResponseWriter, r *http.Request) {})
+	api.Get("/api/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-From", "api")
+	})
+
+	static := builder.New()
+	static.Get("/static/app.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app.js"))
+	})
+	static.Get("/static/upload", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upload form"))
+	})
+
+	h := Fallback(New(api.RouteDefs()), New(static.RouteDefs()))
+
+	// Matched by the primary.
+	w := sendRequest(h, "GET", "/api/users")
+	assert.Equal(t, "users", w.Body.String())
+	assert.Equal(t, "api", w.Header().Get("X-From"))
+
+	// Responses without a body still get the primary's headers.
+	w = sendRequest(h, "GET", "/api/empty")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "api", w.Header().Get("X-From"))
+
+	// Falls through on a 404, discarding the primary's response.
+	w = sendRequest(h, "GET", "/static/app.js")
+	assert.Equal(t, "app.js", w.Body.String())
+
+	// By default, 405s fall through too...
+	w = sendRequest(h, "GET", "/static/upload")
+	assert.Equal(t, "upload form", w.Body.String())
+	assert.Equal(t, "", w.Header().Get("Allow"))
+
+	// ... unless configured otherwise.
+	h = FallbackWithConfig(New(api.RouteDefs()), New(static.RouteDefs()), FallbackConfig{
+		OnlyNotFound: true,
+	})
+	w = sendRequest(h, "GET", "/static/upload")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+
+	// Unmatched by both.
+	w = sendRequest(h, "GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFallbackBody(t *testing.T) {
+	t.Parallel()
+
+	// The secondary sees the whole body, even if the primary read some of it.
+	var got string
+	primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 5)
+		r.Body.Read(buf)
+		r.Body.Close()
+		http.NotFound(w, r)
+	})
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = string(body)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("hello, world"))
+	Fallback(primary, secondary).ServeHTTP(w, r)
+	assert.Equal(t, "hello, world", got)
+}
+
+func TestFallbackBodyCommitted(t *testing.T) {
+	t.Parallel()
+
+	// Once the primary has committed to a response, the body it reads is no
+	// longer recorded.
+	var rb *replayBody
+	primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rb = r.Body.(*replayBody)
+		buf := make([]byte, 5)
+		r.Body.Read(buf)
+		assert.Equal(t, 5, rb.buf.Len())
+
+		w.WriteHeader(http.StatusOK)
+		ioutil.ReadAll(r.Body)
+	})
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("hello, world"))
+	Fallback(primary, secondary).ServeHTTP(w, r)
+	assert.Equal(t, 0, rb.buf.Len())
+}
+
+// hijackRecorder is a ResponseRecorder that can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked    bool
+	wroteHeader bool
+}
+
+func (w *hijackRecorder) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestFallbackWriter(t *testing.T) {
+	t.Parallel()
+
+	var unwrapped http.ResponseWriter
+	secondaryCalled := false
+	primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unwrapped = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap()
+		http.NewResponseController(w).Hijack()
+	})
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryCalled = true
+	})
+
+	// A hijacked request never falls through, and gets no implicit response.
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r, _ := http.NewRequest("GET", "/", nil)
+	Fallback(primary, secondary).ServeHTTP(w, r)
+	assert.Equal(t, w, unwrapped)
+	assert.True(t, w.hijacked)
+	assert.False(t, secondaryCalled)
+	assert.False(t, w.wroteHeader)
+
+	// Writers that can't be hijacked report that.
+	var err error
+	primary = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, err = http.NewResponseController(w).Hijack()
+	})
+	Fallback(primary, secondary).ServeHTTP(httptest.NewRecorder(), r)
+	assert.Error(t, err)
+}
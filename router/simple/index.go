@@ -0,0 +1,75 @@
+package simple
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/andrew-d/wolf/router"
+)
+
+// methodEntry is a single route in a methodIndex.
+type methodEntry struct {
+	method  string
+	pattern router.Pattern
+}
+
+// methodIndex groups every route (regardless of method) by the prefix of its
+// pattern.  Finding the methods that a path matches under only requires running
+// the patterns in groups whose prefix the path starts with, rather than every
+// pattern in the router.  Patterns without a prefix are placed in the group for
+// the empty string, which is always checked.
+//
+// The index is built at the same time as the per-method route table, and is
+// never modified afterwards.
+type methodIndex struct {
+	// All distinct prefixes, in sorted order.
+	prefixes []string
+
+	// Map of prefix --> routes with that prefix
+	groups map[string][]methodEntry
+}
+
+func newMethodIndex() *methodIndex {
+	return &methodIndex{groups: make(map[string][]methodEntry)}
+}
+
+// add inserts the given route into the index.
+func (idx *methodIndex) add(method string, p router.Pattern) {
+	prefix := p.Prefix()
+	if _, ok := idx.groups[prefix]; !ok {
+		i := sort.SearchStrings(idx.prefixes, prefix)
+		idx.prefixes = append(idx.prefixes, "")
+		copy(idx.prefixes[i+1:], idx.prefixes[i:])
+		idx.prefixes[i] = prefix
+	}
+
+	idx.groups[prefix] = append(idx.groups[prefix], methodEntry{method, p})
+}
+
+// methods returns the sorted set of methods under which the given request's
+// path matches some route.
+func (idx *methodIndex) methods(r *http.Request) []string {
+	seen := make(map[string]struct{})
+	for _, prefix := range idx.prefixes {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			continue
+		}
+
+		for _, e := range idx.groups[prefix] {
+			if _, ok := seen[e.method]; ok {
+				continue
+			}
+			if e.pattern.Match(r) {
+				seen[e.method] = struct{}{}
+			}
+		}
+	}
+
+	ret := make([]string, 0, len(seen))
+	for method := range seen {
+		ret = append(ret, method)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -1,13 +1,20 @@
 package simple
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/andrew-d/wolf/builder"
 	"github.com/andrew-d/wolf/middleware"
 	"github.com/andrew-d/wolf/router"
+	"github.com/andrew-d/wolf/types"
 )
 
 // A combination of a route's pattern, handler, and the middleware stack.
@@ -15,81 +22,517 @@ type route struct {
 	pattern router.Pattern
 	handler router.Handler
 	mware   *middleware.MiddlewareStack
+	meta    map[string]interface{}
+
+	// The route's own timeout (see TimeoutMeta), or zero if it has none.
+	timeout time.Duration
 }
 
+// TimeoutMeta is the key of a route's metadata (see builder.RouteConfig.Meta)
+// that holds its timeout, as a time.Duration.  For example:
+//
+//	b.Get("/report", handler).Meta(simple.TimeoutMeta, 5*time.Second)
+//
+// The timeout is applied in the same way as DefaultTimeout, which it replaces
+// for that route, whether it is shorter or longer.  New panics if the value is
+// not a time.Duration.
+const TimeoutMeta = "timeout"
+
 // SimpleRouter is the simplest-possible router - it checks each route in
 // sequence for a match, and dispatches to the first one.
+//
+// Trailing slashes are always significant: "/foo" and "/foo/" are different
+// paths, and the router will never redirect from one to the other.  This means
+// that the two can be registered as separate routes with different handlers
+// (e.g. for a resource and the collection underneath it).
 type SimpleRouter struct {
 	// Map of HTTP method --> route array
 	routes map[string][]route
 
-	// NotFound will be run whenever no route is matched (if non-nil).
+	// Scoped NotFound handlers (see builder.Builder.NotFound), ordered from
+	// the most to the least specific prefix.
+	notFounds []route
+
+	// Index of all routes by prefix, used to find all methods for a path.
+	index *methodIndex
+
+	// Global middleware, which wraps the routing of every request.
+	global *middleware.MiddlewareStack
+
+	// Pre-routing middleware, built from PreRouting on the first request.
+	pre     *middleware.MiddlewareStack
+	preOnce sync.Once
+
+	// PreRouting is a list of middleware that run immediately before a
+	// route is matched, and can modify the request (e.g. its path, host or
+	// method) to change which route is selected.  They run in order, inside
+	// any global middleware (see Use), and wrap the matched route's own
+	// middleware, or the NotFound or MethodNotAllowed handler if no route
+	// matches.  Global middleware therefore sees the request as it was
+	// originally received.
+	//
+	// The middleware stack is built when the first request is served, so
+	// PreRouting must be set before then; later changes have no effect.
+	PreRouting []types.MiddlewareType
+
+	// NotFound will be run whenever no route is matched (if non-nil).  It is
+	// given the context as modified by the global middleware, so that values
+	// such as the request ID are available to it.
+	//
+	// NotFound handlers registered on a builder (see builder.Builder.NotFound)
+	// take precedence over this one for requests under the builder's prefix.
+	// If several match, the handler for the longest prefix is run, even if
+	// the handlers came from different builders (see NewFromBuilders), so
+	// that requests fall back from the innermost mounted builder outwards,
+	// and finally to this handler.  A 405 response is always preferred over
+	// any NotFound handler, though.
 	NotFound router.Handler
+
+	// MethodNotAllowed will be run instead of NotFound when no route is
+	// matched, but the request's path does match a route for some other
+	// method (if non-nil).  The methods that the path can be served with are
+	// available from the context with router.GetAllowedMethods, and have
+	// already been set in the Allow header.  If nil, a 405 Method Not Allowed
+	// response is sent.
+	MethodNotAllowed router.Handler
+
+	// InternalError will be run whenever an error-returning handler returns
+	// an error (if non-nil).  It runs inside the route's middleware, as soon
+	// as the handler returns, so that the middleware sees the error response,
+	// and it is given the handler's context, including URL parameters and any
+	// values set by the middleware.  The error can be retrieved from the
+	// context with router.GetError.  If nil, router.DefaultOnError is used.
+	// Handlers that implement router.ErrorHandler render their own errors,
+	// and never reach this.
+	InternalError router.Handler
+
+	// NormalizeMethod, if true, causes the request's method to be upper-cased
+	// before looking up routes, so that requests from misbehaving clients
+	// (e.g. a method of "get") will still match.  The request itself is not
+	// modified.  Since HTTP methods are case-sensitive, this is off by
+	// default.
+	NormalizeMethod bool
+
+	// DefaultTimeout, if non-zero, is applied as a timeout to the context of
+	// every matched route (including scoped NotFound handlers), starting from
+	// when the route is matched.  Routes with a timeout in their metadata (see
+	// TimeoutMeta) use that instead.  Middleware attached to a route can further
+	// shorten the timeout (e.g. with context.WithTimeout), but can't extend
+	// it, since a derived context can't outlive its parent.
+	//
+	// Handlers can't be interrupted, so they should watch the context to
+	// return promptly.  If the timeout has expired by the time the handler
+	// returns, and nothing has been written, TimeoutHandler is run.
+	DefaultTimeout time.Duration
+
+	// TimeoutHandler will be run when a route's handler returns without
+	// writing a response after its timeout (see DefaultTimeout) has expired
+	// (if non-nil).  If nil, a 503 Service Unavailable response is sent.
+	TimeoutHandler router.Handler
+
+	// Encoders, if non-nil, is used to encode the values returned by
+	// router.ValueFunc handlers, instead of router.DefaultEncoders.
+	Encoders *router.EncoderRegistry
+
+	// PathFunc, if non-nil, supplies the path used to route each request,
+	// instead of r.URL.Path (e.g. to route on a header set by a proxy).  Since
+	// patterns match against the request's URL, routing is done with a copy
+	// of the request whose URL.Path is replaced by the returned path, and
+	// whose URL.RawPath is cleared.  The copy is what the matched route's
+	// middleware and handler receive, so parameters and wildcards (see
+	// router.GetURLParam) are bound from the returned path, and agree with
+	// r.URL.Path as the handler sees it.  PathFunc is applied before the
+	// PreRouting middleware run, so that they see the copy, and can rewrite
+	// its path further.  Global middleware still sees the original request.
+	PathFunc func(*http.Request) string
+}
+
+// Options configures how a SimpleRouter allocates its route table (see
+// NewWithOptions).  The zero value sizes the table exactly to fit the given
+// routes, which is what New uses.
+type Options struct {
+	// InitialMethodCapacity is the minimum number of methods to allocate
+	// space for in the route table.  The table always has room for every
+	// method (including non-standard ones, e.g. PROPFIND) that the routes are
+	// registered with.
+	InitialMethodCapacity int
+
+	// PerMethodCapacity is the minimum capacity of the list of routes for
+	// each method.  Each list always has room for all of the routes
+	// registered with its method.
+	PerMethodCapacity int
 }
 
 // New takes a list of route definitions (generally created by using the
-// builder package) and returns a router instance.
+// builder package) and returns a router instance.  It is equivalent to
+// NewWithOptions with the zero Options.
 func New(routeDefs []builder.RouteDef) *SimpleRouter {
+	return NewWithOptions(routeDefs, Options{})
+}
+
+// NewWithOptions is like New, but allows tuning the allocation of the route
+// table with the given options.
+func NewWithOptions(routeDefs []builder.RouteDef, opts Options) *SimpleRouter {
+	// Count the routes for each method, so that each method's routes can be
+	// allocated with exactly the right size.  Methods are canonicalized to
+	// upper-case, so that they can be matched when NormalizeMethod is set.
+	counts := make(map[string]int)
+	for _, def := range routeDefs {
+		if def.Method != builder.MethodNotFound {
+			counts[strings.ToUpper(def.Method)]++
+		}
+	}
+
 	// Iterate over all the route definitions and save the routes for each
 	// method in a map, indexed by HTTP method.
-	//
-	// Note: The `9` below == number of helper methods we have.
-	methods := make(map[string][]route, 9)
+	s := &SimpleRouter{}
+	methods := make(map[string][]route, max(len(counts), opts.InitialMethodCapacity))
+	for method, n := range counts {
+		methods[method] = make([]route, 0, max(n, opts.PerMethodCapacity))
+	}
+	index := newMethodIndex()
+	var notFounds []route
 	for _, def := range routeDefs {
 		// A route contains a parsed pattern and handler.
 		r := route{
 			pattern: router.ParsePattern(def.Pattern),
 			handler: router.MakeHandler(def.Handler),
+			meta:    def.Meta,
+		}
+		if v, ok := def.Meta[TimeoutMeta]; ok {
+			if r.timeout, ok = v.(time.Duration); !ok {
+				msg := fmt.Sprintf(`Invalid %q metadata for route %v: `+
+					`expected a time.Duration, got %T`, TimeoutMeta, def.Pattern, v)
+				panic(msg)
+			}
 		}
 
-		// The middleware's "final function" is simply the handler's serve
-		// function.
-		r.mware = middleware.New(r.handler.ServeHTTPC, def.Middleware)
+		// The middleware's "final function" is the handler's serve function,
+		// followed by rendering any error it returns.
+		r.mware = middleware.New(s.final(r.handler), def.Middleware)
 
-		// Save this route.  For efficiency, we pre-allocate an array with
-		// space for 32 routes for every method we have.
-		arr := methods[def.Method]
-		if arr == nil {
-			arr = make([]route, 0, 32)
+		// Scoped NotFound handlers aren't routes for any method, and so are
+		// kept separately.
+		if def.Method == builder.MethodNotFound {
+			notFounds = append(notFounds, r)
+			continue
 		}
-		methods[def.Method] = append(arr, r)
+
+		// Save this route.  Routes for any method aren't indexed, since
+		// MethodAny doesn't belong in an Allow header.
+		method := strings.ToUpper(def.Method)
+		methods[method] = append(methods[method], r)
+		if method != builder.MethodAny {
+			index.add(method, r.pattern)
+		}
+	}
+
+	// The builder orders its own NotFound handlers, but those from different
+	// builders (see NewFromBuilders) may still be out of order.
+	sort.SliceStable(notFounds, func(i, j int) bool {
+		return router.Specificity(notFounds[i].pattern) > router.Specificity(notFounds[j].pattern)
+	})
+
+	s.routes, s.notFounds, s.index = methods, notFounds, index
+	s.global = middleware.New(s.preRoute, nil)
+	return s
+}
+
+// NewFromBuilders creates a router from the route definitions of several
+// independent builders.  The definitions of each builder are concatenated in
+// the order the builders are given, so routes from earlier builders take
+// precedence over routes from later ones.
+//
+// Each builder is flattened separately, so middleware attached at the root of
+// one builder (with Use) applies only to the routes registered on that
+// builder, and never to the routes of any other builder.
+func NewFromBuilders(builders ...builder.Builder) *SimpleRouter {
+	var defs []builder.RouteDef
+	for _, b := range builders {
+		defs = append(defs, b.RouteDefs()...)
+	}
+
+	return New(defs)
+}
+
+// Use adds a global middleware to this router.  Global middleware wraps the
+// routing of every request - including requests that do not match any route
+// and are dispatched to the NotFound handler - and runs before any middleware
+// attached to the matched route.
+func (s *SimpleRouter) Use(mw types.MiddlewareType) {
+	s.global.Push(mw)
+}
+
+// SortBySpecificity reorders the routes for each method so that more specific
+// patterns are tried first (see router.SpecificPattern), rather than routes
+// being tried in the order that they were registered.  For example, the route
+// "/users/me" will be preferred over "/users/:id", regardless of which was
+// registered first.  Routes with equal specificity keep their relative order.
+//
+// Since this changes which route matches a request, it's opt-in; it should be
+// called once, before the router is used to serve any requests.
+func (s *SimpleRouter) SortBySpecificity() {
+	for _, routes := range s.routes {
+		sort.SliceStable(routes, func(i, j int) bool {
+			return router.Specificity(routes[i].pattern) > router.Specificity(routes[j].pattern)
+		})
+	}
+}
+
+// AllowedMethods returns the sorted list of HTTP methods for which some route
+// matches the given request, ignoring the request's own method.  Routes
+// registered for any method (see builder.MethodAny) are not included.  Routes
+// are looked up using an index of route prefixes, so this is considerably
+// cheaper than trying every route in the router.
+func (s *SimpleRouter) AllowedMethods(r *http.Request) []string {
+	return s.index.methods(r)
+}
+
+// Lookup returns the pattern of the route that a request with the given method
+// and path would be dispatched to, without running it.  As when serving a
+// request, routes for the method take precedence over those registered for any
+// method, and NormalizeMethod is respected.  Patterns that examine more than
+// the path (e.g. the request's headers) see a request with no headers.
+//
+// If ignoreTrailingSlash is true and no route matches the path, the lookup is
+// retried with a trailing slash added (or removed, if the path has one), so
+// that "/users" finds a route for "/users/" and vice versa.  The path that
+// actually matched is returned along with the pattern.  The boolean is false
+// if no route matched either form.
+func (s *SimpleRouter) Lookup(method, path string, ignoreTrailingSlash bool) (router.Pattern, string, bool) {
+	if s.NormalizeMethod {
+		method = strings.ToUpper(method)
+	}
+
+	if pat, ok := s.lookup(method, path); ok {
+		return pat, path, true
+	}
+	if !ignoreTrailingSlash || path == "/" {
+		return nil, "", false
+	}
+
+	alt := path + "/"
+	if strings.HasSuffix(path, "/") {
+		alt = strings.TrimSuffix(path, "/")
+	}
+	if pat, ok := s.lookup(method, alt); ok {
+		return pat, alt, true
 	}
+	return nil, "", false
+}
 
-	return &SimpleRouter{routes: methods}
+// lookup finds the pattern of the route that matches the given method and
+// path.
+func (s *SimpleRouter) lookup(method, path string) (router.Pattern, bool) {
+	r := &http.Request{
+		Method: method,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	}
+	for _, m := range []string{method, builder.MethodAny} {
+		for _, route := range s.routes[m] {
+			if route.pattern.Match(r) {
+				return route.pattern, true
+			}
+		}
+	}
+	return nil, false
 }
 
 // This function allows SimpleRouter to implement net/http.Handler
 func (s *SimpleRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	found := false
+	s.ServeHTTPC(context.Background(), w, r)
+}
+
+// ServeHTTPC allows SimpleRouter to implement router.Handler, so that it can
+// be used as a handler within another router.  The given context is used as
+// the base context for the global middleware, and from there, for the
+// middleware of the matched route.
+//
+// A value store (see router.WithValueStore) is added to the context of every
+// request that doesn't already have one, so handlers can use router.SetValue
+// to pass values back to the middleware that wraps them, including the
+// middleware of any router that a nested router is mounted in.
+func (s *SimpleRouter) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	stack := s.global.GetWithContext(router.WithValueStore(ctx))
+	stack.Handler.ServeHTTP(w, r)
+	s.global.Release(stack)
+}
+
+// preRoute applies PathFunc and runs the PreRouting middleware, if there are
+// any, before dispatching the request.  It is the final function of the global middleware
+// stack.
+func (s *SimpleRouter) preRoute(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if s.PathFunc != nil {
+		r = withPath(r, s.PathFunc(r))
+	}
+
+	s.preOnce.Do(func() {
+		if len(s.PreRouting) > 0 {
+			s.pre = middleware.New(s.dispatch, s.PreRouting)
+		}
+	})
+	if s.pre == nil {
+		s.dispatch(ctx, w, r)
+		return
+	}
+
+	stack := s.pre.GetWithContext(ctx)
+	stack.Handler.ServeHTTP(w, r)
+	s.pre.Release(stack)
+}
 
-	// Iterate over all routes for this method.
-	for _, route := range s.routes[r.Method] {
+// dispatch finds the route matching the given request and runs it.  It is
+// called once the global and pre-routing middleware have run.  The method that
+// the route was matched under is recorded in its context (see
+// router.GetMatchedMethod), along with the route's metadata (see
+// router.GetRouteMeta).
+//
+// Routes registered for the request's method take precedence over those
+// registered for any method (see builder.MethodAny), which are only tried if
+// none of the former match.
+func (s *SimpleRouter) dispatch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	method := r.Method
+	if s.NormalizeMethod {
+		method = strings.ToUpper(method)
+	}
+
+	if !s.serveMethod(ctx, method, w, r) && !s.serveMethod(ctx, builder.MethodAny, w, r) {
+		s.notFound(ctx, w, r)
+	}
+}
+
+// withPath returns a shallow copy of the request with the given path.
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// serveMethod runs the first of the routes for the given method that matches
+// the request, and returns whether there was one.
+func (s *SimpleRouter) serveMethod(ctx context.Context, method string, w http.ResponseWriter, r *http.Request) bool {
+	return s.serveRoutes(ctx, method, s.routes[method], w, r)
+}
+
+// serveRoutes runs the first of the given routes that matches the request,
+// recording the given method as the one it was matched under, and returns
+// whether there was one.
+func (s *SimpleRouter) serveRoutes(ctx context.Context, method string, routes []route, w http.ResponseWriter, r *http.Request) bool {
+	// Iterate over all the routes.
+	for _, route := range routes {
 		// If the route matches, then we run the matching again in order to
 		// capture any variables from dynamic portions of the route, and then
 		// run the actual handler.
 		//
 		// Note: the handler will actually dispatch to the middleware, and then
 		// the final handler function.
-		if route.pattern.Match(r) {
-			found = true
+		if !route.pattern.Match(r) {
+			continue
+		}
 
-			stack := route.mware.Get()
-			route.pattern.Run(r, &stack.Context)
-			stack.Handler.ServeHTTP(w, r)
-			route.mware.Release(stack)
+		s.serveRoute(ctx, method, route, w, r)
+		return true
+	}
+
+	return false
+}
 
-			break
+// serveRoute runs the given route, which matched the request under the given
+// method, applying its timeout and rendering any error that it returns.
+func (s *SimpleRouter) serveRoute(ctx context.Context, method string, route route, w http.ResponseWriter, r *http.Request) {
+	timeout := s.DefaultTimeout
+	if route.timeout > 0 {
+		timeout = route.timeout
+	}
+
+	var ww middleware.WrapResponseWriter
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// We need to know whether the handler responded before the
+		// deadline passed.
+		var ok bool
+		if ww, ok = w.(middleware.WrapResponseWriter); !ok {
+			ww = middleware.WrapWriter(w)
 		}
+		w = ww
 	}
 
-	// If we didn't get a route, then we either run the user-provided not-found
-	// handler (if provided), or dispatch to the standard library's NotFound
-	// handler.
-	if !found {
-		if s.NotFound != nil {
-			s.NotFound.ServeHTTPC(context.Background(), w, r)
+	ctx = router.WithErrorCapture(router.SetMatchedMethod(ctx, method))
+	if route.meta != nil {
+		ctx = router.SetRouteMeta(ctx, route.meta)
+	}
+	if s.Encoders != nil {
+		ctx = router.WithEncoders(ctx, s.Encoders)
+	}
+	stack := route.mware.GetWithContext(ctx)
+	route.pattern.Run(r, &stack.Context)
+	stack.Handler.ServeHTTP(w, r)
+	route.mware.Release(stack)
+
+	// Any error has already been rendered.
+	if router.GetError(ctx) != nil {
+		return
+	}
+
+	if ww != nil && !ww.Written() && ctx.Err() == context.DeadlineExceeded {
+		if s.TimeoutHandler != nil {
+			s.TimeoutHandler.ServeHTTPC(ctx, w, r)
 		} else {
-			http.NotFound(w, r)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
 		}
 	}
 }
+
+// final returns the final function of a route's middleware stack, which runs
+// the route's handler, and then renders any error that it returned.
+func (s *SimpleRouter) final(h router.Handler) middleware.FinalFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTPC(ctx, w, r)
+
+		if err := router.GetError(ctx); err != nil {
+			if s.InternalError != nil {
+				s.InternalError.ServeHTTPC(ctx, w, r)
+			} else {
+				router.DefaultOnError(ctx, w, r, err)
+			}
+		}
+	}
+}
+
+// notFound handles a request that didn't match any route.  If the path matches
+// a route under some other method, the request is passed to the
+// MethodNotAllowed handler (or is sent a 405 Method Not Allowed response);
+// otherwise, we run the innermost scoped NotFound handler whose prefix matches,
+// then the user-provided not-found handler (if provided), and finally dispatch
+// to the standard library's NotFound handler.  Since we're called
+// from within the global middleware stack, all of these are protected by any
+// global middleware.
+func (s *SimpleRouter) notFound(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if allowed := s.index.methods(r); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if s.MethodNotAllowed != nil {
+			s.MethodNotAllowed.ServeHTTPC(router.SetAllowedMethods(ctx, allowed), w, r)
+		} else {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if s.serveRoutes(ctx, builder.MethodNotFound, s.notFounds, w, r) {
+		return
+	}
+
+	if s.NotFound != nil {
+		s.NotFound.ServeHTTPC(ctx, w, r)
+	} else {
+		http.NotFound(w, r)
+	}
+}
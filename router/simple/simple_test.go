@@ -0,0 +1,1045 @@
+package simple
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/andrew-d/wolf/builder"
+	"github.com/andrew-d/wolf/middleware"
+	"github.com/andrew-d/wolf/router"
+	"github.com/andrew-d/wolf/types"
+)
+
+func sendRequest(h http.Handler, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// A simple recovery middleware, for testing.
+func recoverer(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				http.Error(w, "recovered", http.StatusInternalServerError)
+			}
+		}()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestGlobalMiddleware(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("index"))
+	})
+
+	var calls []string
+	s := New(b.RouteDefs())
+	s.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, r.URL.Path)
+			h.ServeHTTP(w, r)
+		})
+	})
+
+	// Global middleware should run for both matched and unmatched routes.
+	w := sendRequest(s, "GET", "/")
+	assert.Equal(t, "index", w.Body.String())
+	w = sendRequest(s, "GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"/", "/missing"}, calls)
+}
+
+func TestNotFoundRecovered(t *testing.T) {
+	t.Parallel()
+
+	s := New(nil)
+	s.Use(recoverer)
+	s.NotFound = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+
+	w := sendRequest(s, "GET", "/missing")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestFuncPattern(t *testing.T) {
+	t.Parallel()
+
+	hasHeader := router.FuncPattern(func(r *http.Request) bool {
+		return r.Header.Get("X-Beta") == "1"
+	}, nil)
+
+	b := builder.New()
+	b.Get(hasHeader, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("beta"))
+	})
+	b.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stable"))
+	})
+	s := New(b.RouteDefs())
+
+	w := sendRequest(s, "GET", "/")
+	assert.Equal(t, "stable", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Beta", "1")
+	s.ServeHTTP(w, r)
+	assert.Equal(t, "beta", w.Body.String())
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	s := New(b.RouteDefs())
+
+	// Methods are case-sensitive by default...
+	w := sendRequest(s, "get", "/")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// ... unless we normalize them.
+	s.NormalizeMethod = true
+	w = sendRequest(s, "get", "/")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAllowedMethods(t *testing.T) {
+	t.Parallel()
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	b := builder.New()
+	b.Get("/users", noop)
+	b.Post("/users", noop)
+	b.Delete("/users/:id", noop)
+	b.Put(regexp.MustCompile(`^/users/\d+$`), noop)
+	b.Get("/", noop)
+	b.AnyMethod("/proxy/*", noop)
+	b.RouteClean("/users", func(b builder.Builder) {
+		b.NotFound(noop)
+	})
+
+	s := New(b.RouteDefs())
+	methods := func(path string) []string {
+		r, _ := http.NewRequest("GET", path, nil)
+		return s.AllowedMethods(r)
+	}
+
+	assert.Equal(t, []string{"GET", "POST"}, methods("/users"))
+	assert.Equal(t, []string{"DELETE", "PUT"}, methods("/users/123"))
+	assert.Equal(t, []string{"DELETE"}, methods("/users/bob"))
+	assert.Equal(t, []string{"GET"}, methods("/"))
+	assert.Equal(t, []string{}, methods("/missing"))
+	assert.Equal(t, []string{}, methods("/proxy/a"))
+	assert.Equal(t, []string{}, methods("/users/a/b"))
+}
+
+func TestServeHTTPC(t *testing.T) {
+	t.Parallel()
+
+	var seen interface{}
+	b := builder.New()
+	b.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		seen = ctx.Value("key")
+	})
+	s := New(b.RouteDefs())
+
+	// The incoming context should be passed through to the route.
+	var _ router.Handler = s
+	ctx := context.WithValue(context.Background(), "key", "value")
+	r, _ := http.NewRequest("GET", "/", nil)
+	s.ServeHTTPC(ctx, httptest.NewRecorder(), r)
+	assert.Equal(t, "value", seen)
+}
+
+func TestHandlerSetValue(t *testing.T) {
+	t.Parallel()
+
+	var seen interface{}
+	b := builder.New()
+	b.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			seen = router.Value(*ctx, "key")
+		})
+	})
+	b.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		router.SetValue(ctx, "key", "from handler")
+	})
+	s := New(b.RouteDefs())
+
+	// The middleware should see the value set by the handler.
+	sendRequest(s, "GET", "/")
+	assert.Equal(t, "from handler", seen)
+}
+
+func TestNestedRouterSetValue(t *testing.T) {
+	t.Parallel()
+
+	inner := builder.New()
+	inner.Get("/inner", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		router.SetValue(ctx, "key", "from inner handler")
+	})
+
+	var seen interface{}
+	outer := builder.New()
+	outer.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r)
+			seen = router.Value(*ctx, "key")
+		})
+	})
+	outer.Get("/inner", New(inner.RouteDefs()))
+
+	// The outer router's middleware should see the value set by a handler
+	// in the nested router.
+	sendRequest(New(outer.RouteDefs()), "GET", "/inner")
+	assert.Equal(t, "from inner handler", seen)
+}
+
+func TestMountContext(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+
+	sub := builder.New()
+	sub.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "child saw "+(*ctx).Value("key").(string))
+			h.ServeHTTP(w, r)
+		})
+	})
+	sub.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler saw "+ctx.Value("key").(string))
+	})
+
+	b := builder.New()
+	b.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = context.WithValue(*ctx, "key", "parent")
+			h.ServeHTTP(w, r)
+		})
+	})
+	b.MountInherit("", sub)
+
+	sendRequest(New(b.RouteDefs()), "GET", "/")
+	assert.Equal(t, []string{"child saw parent", "handler saw parent"}, calls)
+}
+
+func TestNotPattern(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get(router.And(
+		router.ParseStringPattern("/api/*"),
+		router.Not(router.ParseStringPattern("/api/health")),
+	), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api"))
+	})
+	b.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("health"))
+	})
+	s := New(b.RouteDefs())
+
+	assert.Equal(t, "api", sendRequest(s, "GET", "/api/users").Body.String())
+	assert.Equal(t, "health", sendRequest(s, "GET", "/api/health").Body.String())
+}
+
+func TestStrictSlash(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/foo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("resource"))
+	})
+	b.Get("/foo/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("collection"))
+	})
+	b.Get("/bar", func(w http.ResponseWriter, r *http.Request) {})
+	s := New(b.RouteDefs())
+
+	assert.Equal(t, "resource", sendRequest(s, "GET", "/foo").Body.String())
+	assert.Equal(t, "collection", sendRequest(s, "GET", "/foo/").Body.String())
+
+	// Paths that differ only by a trailing slash are not redirected.
+	w := sendRequest(s, "GET", "/bar/")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "", w.Header().Get("Location"))
+}
+
+func TestNestedRouterParams(t *testing.T) {
+	t.Parallel()
+
+	var params map[string]string
+
+	child := builder.New()
+	child.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		params = router.GetURLParams(ctx)
+		w.Write([]byte(r.URL.Path))
+	})
+
+	parent := builder.New()
+	parent.Get("/api/:version/*", router.StripPrefix(New(child.RouteDefs())))
+
+	w := sendRequest(New(parent.RouteDefs()), "GET", "/api/v2/users/42")
+	assert.Equal(t, "/users/42", w.Body.String())
+	assert.Equal(t, map[string]string{
+		"version": "v2",
+		"id":      "42",
+	}, params)
+}
+
+func TestQueryPattern(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get(router.And(
+		router.ParseStringPattern("/report"),
+		router.QueryPattern("format", "csv"),
+	), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("csv"))
+	})
+	b.Get(router.And(
+		router.ParseStringPattern("/report"),
+		router.QueryPattern("format", "pdf"),
+	), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pdf"))
+	})
+	s := New(b.RouteDefs())
+
+	assert.Equal(t, "csv", sendRequest(s, "GET", "/report?format=csv").Body.String())
+	assert.Equal(t, "pdf", sendRequest(s, "GET", "/report?format=pdf").Body.String())
+	assert.Equal(t, http.StatusNotFound, sendRequest(s, "GET", "/report").Code)
+}
+
+func TestMatchedMethod(t *testing.T) {
+	t.Parallel()
+
+	var (
+		method string
+		ok     bool
+	)
+	b := builder.New()
+	b.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		method, ok = router.GetMatchedMethod(ctx)
+	})
+	s := New(b.RouteDefs())
+	s.NormalizeMethod = true
+
+	// The matched method is the normalized one, not the request's.
+	sendRequest(s, "get", "/")
+	assert.True(t, ok)
+	assert.Equal(t, "GET", method)
+}
+
+func TestSortBySpecificity(t *testing.T) {
+	t.Parallel()
+
+	handler := func(name string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+	b := builder.New()
+	b.Get("/users/*", handler("wildcard"))
+	b.Get(regexp.MustCompile(`^/users/(?P<id>\d+)$`), handler("regexp"))
+	b.Get("/users/:id", handler("param"))
+	b.Get("/users/me", handler("static"))
+
+	// By default, routes are matched in registration order.
+	s := New(b.RouteDefs())
+	assert.Equal(t, "wildcard", sendRequest(s, "GET", "/users/me").Body.String())
+
+	s.SortBySpecificity()
+	assert.Equal(t, "static", sendRequest(s, "GET", "/users/me").Body.String())
+	assert.Equal(t, "param", sendRequest(s, "GET", "/users/123").Body.String())
+	assert.Equal(t, "wildcard", sendRequest(s, "GET", "/users/a/b").Body.String())
+}
+
+func TestInternalError(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/fail", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errors.New("oops")
+	})
+	b.Get("/ok", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("ok"))
+		return nil
+	})
+	s := New(b.RouteDefs())
+
+	// By default, errors are rendered with DefaultOnError.
+	w := sendRequest(s, "GET", "/fail")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "oops\n", w.Body.String())
+
+	s.InternalError = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("custom: " + router.GetError(ctx).Error()))
+	})
+	w = sendRequest(s, "GET", "/fail")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "custom: oops", w.Body.String())
+
+	w = sendRequest(s, "GET", "/ok")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestInternalErrorInsideMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+	var status int
+	b := builder.New()
+	b.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ctx = context.WithValue(*ctx, key{}, "value")
+			ww := middleware.WrapWriter(w)
+			h.ServeHTTP(ww, r)
+			status = ww.Status()
+		})
+	})
+	b.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errors.New("oops")
+	})
+	s := New(b.RouteDefs())
+
+	// Middleware sees the rendered error.
+	w := sendRequest(s, "GET", "/users/123")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, status)
+
+	// The error handler is given the handler's context.
+	s.InternalError = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "%s %s", router.GetURLParams(ctx)["id"], ctx.Value(key{}))
+	})
+	w = sendRequest(s, "GET", "/users/123")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "123 value", w.Body.String())
+	assert.Equal(t, http.StatusBadGateway, status)
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + router.GetURLParams(ctx)["id"]))
+	})
+	s := New(b.RouteDefs())
+	s.Use(middleware.Rewrite([]middleware.RewriteRule{
+		{Pattern: regexp.MustCompile(`^/v1(/.*)$`), Replacement: "$1"},
+	}))
+
+	assert.Equal(t, "user 123", sendRequest(s, "GET", "/v1/users/123").Body.String())
+	assert.Equal(t, "user 456", sendRequest(s, "GET", "/users/456").Body.String())
+}
+
+func TestRouteMeta(t *testing.T) {
+	t.Parallel()
+
+	var scope interface{}
+	b := builder.New()
+	b.Use(func(ctx *context.Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope = router.GetRouteMeta(*ctx)["scope"]
+			h.ServeHTTP(w, r)
+		})
+	})
+	b.Get("/admin", func(w http.ResponseWriter, r *http.Request) {}).Meta("scope", "admin")
+	b.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	s := New(b.RouteDefs())
+
+	sendRequest(s, "GET", "/admin")
+	assert.Equal(t, "admin", scope)
+
+	sendRequest(s, "GET", "/")
+	assert.Nil(t, scope)
+}
+
+func TestNotFoundContext(t *testing.T) {
+	t.Parallel()
+
+	var id string
+	s := New(builder.New().RouteDefs())
+	s.Use(middleware.RequestID())
+	s.NotFound = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		id = middleware.GetRequestID(ctx)
+		http.NotFound(w, r)
+	})
+
+	w := sendRequest(s, "GET", "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotEqual(t, "", id)
+	assert.Equal(t, w.Header().Get(middleware.RequestIDHeader), id)
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	b := builder.New()
+	b.Get("/users", noop)
+	b.Post("/users", noop)
+	s := New(b.RouteDefs())
+
+	// Paths that match under another method get a 405...
+	w := sendRequest(s, "DELETE", "/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+
+	// ... while those that don't match at all get a 404.
+	w = sendRequest(s, "DELETE", "/other")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "", w.Header().Get("Allow"))
+
+	// Custom handlers can tell the two cases apart.
+	var allowed []string
+	s.MethodNotAllowed = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		allowed = router.GetAllowedMethods(ctx)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	s.NotFound = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	assert.Equal(t, http.StatusTeapot, sendRequest(s, "PUT", "/users").Code)
+	assert.Equal(t, []string{"GET", "POST"}, allowed)
+	assert.Equal(t, http.StatusGone, sendRequest(s, "PUT", "/other").Code)
+}
+
+func TestAnyMethod(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.AnyMethod("/dav/*", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		method, _ := router.GetMatchedMethod(ctx)
+		w.Write([]byte("any " + r.Method + " " + method))
+	})
+	b.Get("/dav/file", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get"))
+	})
+	s := New(b.RouteDefs())
+
+	// Exact methods take precedence, regardless of registration order.
+	assert.Equal(t, "get", sendRequest(s, "GET", "/dav/file").Body.String())
+
+	// Any other method falls back to the wildcard route.
+	assert.Equal(t, "any PROPFIND *", sendRequest(s, "PROPFIND", "/dav/file").Body.String())
+	assert.Equal(t, "any GET *", sendRequest(s, "GET", "/dav/other").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, sendRequest(s, "PROPFIND", "/other").Code)
+}
+
+func TestRouteTableSizes(t *testing.T) {
+	t.Parallel()
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	b := builder.New()
+	b.Get("/a", noop)
+	b.Get("/b", noop)
+	b.Handle("get", "/c", noop)
+	b.Post("/a", noop)
+
+	s := New(b.RouteDefs())
+	assert.Len(t, s.routes, 2)
+	for method, routes := range s.routes {
+		assert.Equal(t, len(routes), cap(routes), method)
+	}
+	assert.Len(t, s.routes["GET"], 3)
+
+	// Options set minimum capacities, and custom methods are supported.
+	b.Handle("PROPFIND", "/a", noop)
+	s = NewWithOptions(b.RouteDefs(), Options{PerMethodCapacity: 2})
+	assert.Len(t, s.routes, 3)
+	assert.Equal(t, 3, cap(s.routes["GET"]))
+	assert.Equal(t, 2, cap(s.routes["POST"]))
+	assert.Equal(t, 2, cap(s.routes["PROPFIND"]))
+	assert.Equal(t, http.StatusOK, sendRequest(s, "PROPFIND", "/a").Code)
+}
+
+func TestScopedNotFound(t *testing.T) {
+	t.Parallel()
+
+	api := builder.New()
+	api.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	api.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	})
+
+	b := builder.New()
+	b.Mount("/api", api)
+	b.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	s := New(b.RouteDefs())
+	s.NotFound = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "global", http.StatusNotFound)
+	})
+
+	assert.Equal(t, "users", sendRequest(s, "GET", "/api/users").Body.String())
+	assert.Equal(t, "global\n", sendRequest(s, "GET", "/users").Body.String())
+
+	// Requests under the mount prefix use the mounted builder's handler...
+	w := sendRequest(s, "GET", "/api/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"error":"not found"}`, w.Body.String())
+
+	// ... while others use the router's.
+	w = sendRequest(s, "GET", "/apix")
+	assert.Equal(t, "global\n", w.Body.String())
+	w = sendRequest(s, "GET", "/totally/elsewhere")
+	assert.Equal(t, "global\n", w.Body.String())
+
+	// A 405 takes precedence over either.
+	w = sendRequest(s, "POST", "/api/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET", w.Header().Get("Allow"))
+
+	// Requests with the special method never match a scoped handler as a
+	// route.
+	w = sendRequest(s, builder.MethodNotFound, "/api/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	t.Parallel()
+
+	var remaining time.Duration
+	slow := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		deadline, _ := ctx.Deadline()
+		remaining = time.Until(deadline)
+		<-ctx.Done()
+	}
+	withTimeout := func(d time.Duration) func(*context.Context, http.Handler) http.Handler {
+		return func(ctx *context.Context, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var cancel context.CancelFunc
+				*ctx, cancel = context.WithTimeout(*ctx, d)
+				defer cancel()
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	b := builder.New()
+	b.Get("/slow", slow)
+	b.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	})
+	b.Group(func(b builder.Builder) {
+		b.Use(withTimeout(time.Millisecond))
+		b.Get("/tighter", slow)
+	})
+	b.Group(func(b builder.Builder) {
+		b.Use(withTimeout(time.Hour))
+		b.Get("/looser", slow)
+	})
+
+	s := New(b.RouteDefs())
+	s.DefaultTimeout = 20 * time.Millisecond
+
+	// Slow handlers are sent a 503 by default...
+	w := sendRequest(s, "GET", "/slow")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.True(t, remaining <= 20*time.Millisecond)
+
+	// ... but fast ones aren't affected.
+	w = sendRequest(s, "GET", "/fast")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fast", w.Body.String())
+
+	// Routes can tighten the timeout, in which case they handle it
+	// themselves...
+	w = sendRequest(s, "GET", "/tighter")
+	assert.True(t, remaining <= time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// ... but can't loosen it.
+	w = sendRequest(s, "GET", "/looser")
+	assert.True(t, remaining <= 20*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// The response can be customized.
+	s.TimeoutHandler = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too slow", http.StatusGatewayTimeout)
+	})
+	w = sendRequest(s, "GET", "/slow")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "too slow\n", w.Body.String())
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	b := builder.New()
+	b.Get("/users/", noop)
+	b.Get("/posts", noop)
+	b.Get("/posts/:id", noop)
+	b.AnyMethod("/any", noop)
+	s := New(b.RouteDefs())
+
+	pat, path, ok := s.Lookup("GET", "/posts/1", false)
+	assert.True(t, ok)
+	assert.Equal(t, "/posts/1", path)
+	assert.Equal(t, router.ParsePattern("/posts/:id"), pat)
+
+	_, _, ok = s.Lookup("POST", "/any", false)
+	assert.True(t, ok)
+	_, _, ok = s.Lookup("POST", "/posts", false)
+	assert.False(t, ok)
+
+	// By default, trailing slashes are significant.
+	_, _, ok = s.Lookup("GET", "/users", false)
+	assert.False(t, ok)
+	_, _, ok = s.Lookup("GET", "/posts/", false)
+	assert.False(t, ok)
+
+	// Otherwise, the slash is toggled, and the matching form returned.
+	pat, path, ok = s.Lookup("GET", "/users", true)
+	assert.True(t, ok)
+	assert.Equal(t, "/users/", path)
+	assert.Equal(t, router.ParsePattern("/users/"), pat)
+
+	_, path, ok = s.Lookup("GET", "/posts/", true)
+	assert.True(t, ok)
+	assert.Equal(t, "/posts", path)
+
+	_, _, ok = s.Lookup("GET", "/missing", true)
+	assert.False(t, ok)
+}
+
+func TestPreRouting(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+" "+r.Method+" "+r.URL.Path)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	overrideMethod := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m := r.Header.Get("X-HTTP-Method-Override"); m != "" {
+				r = r.WithContext(r.Context())
+				r.Method = m
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	b := builder.New()
+	b.Use(record("route"))
+	b.Delete("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("deleted " + router.GetURLParams(ctx)["id"]))
+	})
+
+	s := New(b.RouteDefs())
+	s.Use(record("global"))
+	s.PreRouting = []types.MiddlewareType{
+		middleware.Rewrite([]middleware.RewriteRule{
+			{Pattern: regexp.MustCompile(`^/v1(/.*)$`), Replacement: "$1"},
+		}),
+		overrideMethod,
+		record("pre"),
+	}
+
+	r, _ := http.NewRequest("POST", "/v1/users/123", nil)
+	r.Header.Set("X-HTTP-Method-Override", "DELETE")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	assert.Equal(t, "deleted 123", w.Body.String())
+	assert.Equal(t, []string{
+		"global POST /v1/users/123",
+		"pre DELETE /users/123",
+		"route DELETE /users/123",
+	}, order)
+}
+
+func TestNewFromBuilders(t *testing.T) {
+	t.Parallel()
+
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	respond := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s))
+		}
+	}
+
+	users := builder.New()
+	users.Use(tag("users"))
+	users.Get("/users", respond("users"))
+	users.Get("/shared", respond("users"))
+
+	posts := builder.New()
+	posts.Use(tag("posts"))
+	posts.Get("/posts", respond("posts"))
+	posts.Get("/shared", respond("posts"))
+
+	s := NewFromBuilders(users, posts)
+
+	// Each builder's middleware only applies to its own routes.
+	w := sendRequest(s, "GET", "/users")
+	assert.Equal(t, "users", w.Body.String())
+	assert.Equal(t, []string{"users"}, w.Header()["X-Middleware"])
+
+	w = sendRequest(s, "GET", "/posts")
+	assert.Equal(t, "posts", w.Body.String())
+	assert.Equal(t, []string{"posts"}, w.Header()["X-Middleware"])
+
+	// Routes from earlier builders take precedence.
+	assert.Equal(t, "users", sendRequest(s, "GET", "/shared").Body.String())
+	assert.Equal(t, "posts", sendRequest(NewFromBuilders(posts, users), "GET", "/shared").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, sendRequest(NewFromBuilders(), "GET", "/users").Code)
+}
+
+func TestNestedNotFound(t *testing.T) {
+	t.Parallel()
+
+	notFound := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, name, http.StatusNotFound)
+		}
+	}
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	v1 := builder.New()
+	v1.Get("/users", noop)
+	v1.NotFound(notFound("v1"))
+
+	api := builder.New()
+	api.NotFound(notFound("api"))
+	api.Mount("/v1", v1)
+	api.Route("/v2", func(b builder.Builder) {
+		b.Get("/users", noop)
+	})
+
+	b := builder.New()
+	b.Mount("/api", api)
+
+	// The most specific prefix wins, falling back outwards...
+	s := New(b.RouteDefs())
+	assert.Equal(t, "v1\n", sendRequest(s, "GET", "/api/v1/missing").Body.String())
+	assert.Equal(t, "api\n", sendRequest(s, "GET", "/api/v2/missing").Body.String())
+	assert.Equal(t, "api\n", sendRequest(s, "GET", "/api/missing").Body.String())
+	assert.Equal(t, "404 page not found\n", sendRequest(s, "GET", "/missing").Body.String())
+
+	// ... even across independent builders, regardless of their order.
+	site := builder.New()
+	site.Get("/", noop)
+	site.NotFound(notFound("site"))
+
+	for _, s := range []*SimpleRouter{NewFromBuilders(site, b), NewFromBuilders(b, site)} {
+		assert.Equal(t, "v1\n", sendRequest(s, "GET", "/api/v1/missing").Body.String())
+		assert.Equal(t, "api\n", sendRequest(s, "GET", "/api/missing").Body.String())
+		assert.Equal(t, "site\n", sendRequest(s, "GET", "/missing").Body.String())
+	}
+}
+
+func TestPathFunc(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + router.GetURLParams(ctx)["id"] + " " + r.URL.Path))
+	})
+	b.Get("/static/*", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		name, _ := router.GetURLParam(ctx, "*")
+		w.Write([]byte("file " + name))
+	})
+
+	var seen string
+	s := New(b.RouteDefs())
+	s.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r.URL.Path
+			h.ServeHTTP(w, r)
+		})
+	})
+	s.PathFunc = func(r *http.Request) string {
+		if p := r.Header.Get("X-Original-URI"); p != "" {
+			return p
+		}
+		return r.URL.Path
+	}
+
+	send := func(path, original string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		if original != "" {
+			r.Header.Set("X-Original-URI", original)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	// The header is used for routing, and parameters are bound from it.
+	w := send("/internal", "/users/123")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user 123 /users/123", w.Body.String())
+	assert.Equal(t, "/internal", seen)
+
+	w = send("/internal", "/static/css/site.css")
+	assert.Equal(t, "file /css/site.css", w.Body.String())
+
+	// Without the header, the request's own path is used.
+	w = send("/users/456", "")
+	assert.Equal(t, "user 456 /users/456", w.Body.String())
+
+	w = send("/users/456", "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPathFuncPreRouting(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + router.GetURLParams(ctx)["id"]))
+	})
+
+	// PreRouting middleware see the path from PathFunc, and their rewrites
+	// are kept.
+	var seen string
+	s := New(b.RouteDefs())
+	s.PathFunc = func(r *http.Request) string {
+		return r.Header.Get("X-Original-URI")
+	}
+	s.PreRouting = []types.MiddlewareType{
+		func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = r.URL.Path
+				r.URL.Path = strings.TrimPrefix(r.URL.Path, "/v1")
+				h.ServeHTTP(w, r)
+			})
+		},
+	}
+
+	r, _ := http.NewRequest("GET", "/internal", nil)
+	r.Header.Set("X-Original-URI", "/v1/users/123")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, "/v1/users/123", seen)
+	assert.Equal(t, "user 123", w.Body.String())
+	assert.Equal(t, "/internal", r.URL.Path)
+}
+
+func TestEncoders(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/users/:id", func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return map[string]string{"id": router.GetURLParams(ctx)["id"]}, nil
+	})
+
+	s := New(b.RouteDefs())
+	w := sendRequest(s, "GET", "/users/123")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"id":"123"}`+"\n", w.Body.String())
+
+	s.Encoders = router.NewEncoderRegistry()
+	s.Encoders.Register("text/plain", func(w io.Writer, v interface{}) error {
+		_, err := fmt.Fprint(w, v)
+		return err
+	})
+	w = sendRequest(s, "GET", "/users/123")
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "map[id:123]", w.Body.String())
+}
+
+func TestRouteTimeout(t *testing.T) {
+	t.Parallel()
+
+	var remaining time.Duration
+	slow := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		deadline, _ := ctx.Deadline()
+		remaining = time.Until(deadline)
+		<-ctx.Done()
+	}
+
+	b := builder.New()
+	b.Get("/report", slow).Meta(TimeoutMeta, 10*time.Millisecond)
+	b.Get("/export", slow).Meta(TimeoutMeta, time.Hour)
+	b.Get("/other", slow)
+
+	s := New(b.RouteDefs())
+	s.TimeoutHandler = router.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "timed out", http.StatusGatewayTimeout)
+	})
+
+	// Without a DefaultTimeout, only the route's own timeout applies.
+	w := sendRequest(s, "GET", "/report")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "timed out\n", w.Body.String())
+	assert.True(t, remaining <= 10*time.Millisecond)
+
+	// The route's timeout replaces DefaultTimeout, even if it's longer.
+	s.DefaultTimeout = time.Millisecond
+	w = sendRequest(s, "GET", "/report")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.True(t, remaining > time.Millisecond)
+
+	w = sendRequest(s, "GET", "/other")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.True(t, remaining <= time.Millisecond)
+
+	// A route's timeout can't extend a deadline that the request already
+	// has, though.
+	s.DefaultTimeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/export", nil)
+	s.ServeHTTPC(ctx, w, r)
+	assert.True(t, remaining <= 20*time.Millisecond)
+}
+
+func TestRouteTimeoutInvalid(t *testing.T) {
+	t.Parallel()
+
+	b := builder.New()
+	b.Get("/report", func(w http.ResponseWriter, r *http.Request) {}).Meta(TimeoutMeta, 10)
+	assert.Panics(t, func() {
+		New(b.RouteDefs())
+	})
+}
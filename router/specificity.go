@@ -0,0 +1,66 @@
+package router
+
+// SpecificPattern is implemented by patterns that can rank how specific they
+// are - that is, roughly how few paths they can match.  Higher values are more
+// specific.  SimpleRouter can use this to prefer the most specific of several
+// matching routes, regardless of the order in which they were registered.
+//
+// The built-in patterns are scored in tiers, from most to least specific:
+//
+//	3. String patterns without parameters or a wildcard (e.g. "/users/me")
+//	2. String patterns with parameters (e.g. "/users/:id")
+//	1. Regexp patterns
+//	0. String patterns with a trailing wildcard (e.g. "/users/*")
+//
+// Within a tier, patterns with more literal characters are more specific.
+// Patterns that don't implement this interface are considered to have a
+// specificity of 0.
+type SpecificPattern interface {
+	Pattern
+	Specificity() int
+}
+
+// Each tier is worth more than any number of literal characters within the
+// tier below it (for any reasonable pattern length).
+const specificityTier = 1 << 16
+
+// tieredSpecificity combines a tier and a count of literal characters into a
+// single score.
+func tieredSpecificity(tier, literal int) int {
+	if literal >= specificityTier {
+		literal = specificityTier - 1
+	}
+	return tier*specificityTier + literal
+}
+
+// Specificity implements SpecificPattern.
+func (s StringPattern) Specificity() int {
+	literal := 0
+	for _, lit := range s.literals {
+		literal += len(lit)
+	}
+
+	switch {
+	case s.wildcard:
+		return tieredSpecificity(0, literal)
+	case len(s.pats) > 0:
+		return tieredSpecificity(2, literal)
+	default:
+		return tieredSpecificity(3, literal)
+	}
+}
+
+// Specificity implements SpecificPattern.  Since a regexp's literal content
+// can't easily be determined, only the length of its prefix is counted.
+func (p RegexpPattern) Specificity() int {
+	return tieredSpecificity(1, len(p.prefix))
+}
+
+// Specificity returns the specificity of the given pattern, or 0 if it doesn't
+// implement SpecificPattern.
+func Specificity(p Pattern) int {
+	if sp, ok := p.(SpecificPattern); ok {
+		return sp.Specificity()
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecificity(t *testing.T) {
+	t.Parallel()
+
+	// From most to least specific.
+	patterns := []Pattern{
+		ParsePattern("/users/me"),
+		ParsePattern("/users"),
+		ParsePattern("/users/:id/posts"),
+		ParsePattern("/users/:id"),
+		ParsePattern(regexp.MustCompile(`^/users/(?P<id>\d+)$`)),
+		ParsePattern(regexp.MustCompile(`^/(?P<id>\d+)$`)),
+		ParsePattern("/users/*"),
+		ParsePattern("/*"),
+	}
+	for i := 1; i < len(patterns); i++ {
+		assert.True(t, Specificity(patterns[i-1]) > Specificity(patterns[i]),
+			"expected %v to be more specific than %v", patterns[i-1], patterns[i])
+	}
+
+	// Patterns that can't rank themselves are the least specific.
+	fp := FuncPattern(func(r *http.Request) bool { return true }, nil)
+	assert.Equal(t, 0, Specificity(fp))
+}
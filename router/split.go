@@ -0,0 +1,51 @@
+package router
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// splitBuckets is the number of buckets that sticky keys are hashed into.
+const splitBuckets = 10000
+
+// SplitHandler returns a Handler that serves pct percent (from 0 to 100) of
+// requests with a, and the remainder with b.  This can be used to send a
+// portion of traffic to a canary deployment.
+//
+// If key is nil, each request is assigned at random.  Otherwise, key is called
+// to extract a value identifying the client - e.g. its IP address or a cookie -
+// and requests with the same non-empty value are always served by the same
+// handler.  Requests for which key returns "" are assigned at random.
+func SplitHandler(pct float64, a, b Handler, key func(context.Context, *http.Request) string) Handler {
+	threshold := uint32(pct / 100 * splitBuckets)
+	if pct <= 0 {
+		threshold = 0
+	} else if pct >= 100 {
+		threshold = splitBuckets
+	}
+
+	return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var k string
+		if key != nil {
+			k = key(ctx, r)
+		}
+
+		var bucket uint32
+		if k != "" {
+			h := fnv.New32a()
+			h.Write([]byte(k))
+			bucket = h.Sum32() % splitBuckets
+		} else {
+			bucket = uint32(rand.Intn(splitBuckets))
+		}
+
+		if bucket < threshold {
+			a.ServeHTTPC(ctx, w, r)
+		} else {
+			b.ServeHTTPC(ctx, w, r)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func countingHandler(n *int) Handler {
+	return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		*n++
+	})
+}
+
+func TestSplitHandler(t *testing.T) {
+	t.Parallel()
+
+	var a, b int
+	h := SplitHandler(20, countingHandler(&a), countingHandler(&b), nil)
+
+	const total = 10000
+	r, _ := http.NewRequest("GET", "/", nil)
+	for i := 0; i < total; i++ {
+		h.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+	}
+
+	assert.Equal(t, total, a+b)
+	assert.InDelta(t, 0.2, float64(a)/total, 0.03)
+}
+
+func TestSplitHandlerSticky(t *testing.T) {
+	t.Parallel()
+
+	var a, b int
+	key := func(ctx context.Context, r *http.Request) string {
+		return r.RemoteAddr
+	}
+	h := SplitHandler(50, countingHandler(&a), countingHandler(&b), key)
+
+	// Each client is consistently served by one handler...
+	const clients = 2000
+	for i := 0; i < clients; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)
+
+		a, b = 0, 0
+		for j := 0; j < 5; j++ {
+			h.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+		}
+		assert.True(t, a == 5 || b == 5, r.RemoteAddr)
+	}
+
+	// ... and clients are split in roughly the right proportion.
+	var sa, sb int
+	h = SplitHandler(50, countingHandler(&sa), countingHandler(&sb), key)
+	for i := 0; i < clients; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)
+		h.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+	}
+	assert.InDelta(t, 0.5, float64(sa)/clients, 0.05)
+}
+
+func TestSplitHandlerBounds(t *testing.T) {
+	t.Parallel()
+
+	var a, b int
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	h := SplitHandler(0, countingHandler(&a), countingHandler(&b), nil)
+	for i := 0; i < 100; i++ {
+		h.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+	}
+	assert.Equal(t, 0, a)
+
+	a, b = 0, 0
+	h = SplitHandler(100, countingHandler(&a), countingHandler(&b), nil)
+	for i := 0; i < 100; i++ {
+		h.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+	}
+	assert.Equal(t, 0, b)
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// findFlusher looks for a http.Flusher in the given writer or any of the
+// writers that it wraps.
+func findFlusher(w http.ResponseWriter) (http.Flusher, bool) {
+	for {
+		if f, ok := w.(http.Flusher); ok {
+			return f, true
+		}
+
+		u, ok := w.(unwrapper)
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// SSEWriter writes a stream of Server-Sent Events to a client.
+type SSEWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+// NewSSEWriter prepares the given ResponseWriter for sending Server-Sent
+// Events, by setting the appropriate headers.  Each event must be flushed to
+// the client as soon as it's sent, so if the writer (or any writer that it
+// wraps - see Push) doesn't support flushing, http.ErrNotSupported is returned
+// and no headers are set.
+func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
+	f, ok := findFlusher(w)
+	if !ok {
+		return nil, http.ErrNotSupported
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	return &SSEWriter{w: w, f: f}, nil
+}
+
+// Send writes a single event to the client and flushes it.  If event is
+// empty, no event type is sent, and the client will treat it as a "message"
+// event.  Data containing newlines is split over multiple data lines, as
+// required by the format.
+func (s *SSEWriter) Send(event, data string) error {
+	var buf bytes.Buffer
+	if event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(event)
+		buf.WriteByte('\n')
+	}
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
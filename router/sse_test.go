@@ -0,0 +1,44 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// noFlushWriter hides the Flush method of the writer it wraps.
+type noFlushWriter struct {
+	w http.ResponseWriter
+}
+
+func (n noFlushWriter) Header() http.Header         { return n.w.Header() }
+func (n noFlushWriter) Write(b []byte) (int, error) { return n.w.Write(b) }
+func (n noFlushWriter) WriteHeader(code int)        { n.w.WriteHeader(code) }
+
+func TestSSEWriter(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	sse, err := NewSSEWriter(wrappedWriter{w})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+
+	assert.NoError(t, sse.Send("update", "one"))
+	assert.True(t, w.Flushed)
+	assert.NoError(t, sse.Send("", "two\nthree"))
+	assert.Equal(t, "event: update\ndata: one\n\ndata: two\ndata: three\n\n", w.Body.String())
+}
+
+func TestSSEWriterNoFlush(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	_, err := NewSSEWriter(noFlushWriter{w})
+	assert.Equal(t, http.ErrNotSupported, err)
+	assert.Equal(t, "", w.Header().Get("Content-Type"))
+}
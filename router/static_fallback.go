@@ -0,0 +1,53 @@
+package router
+
+import (
+	"net/http"
+	"path"
+
+	"golang.org/x/net/context"
+)
+
+// serveFile serves the named regular file from fs with http.ServeContent,
+// which handles Range, conditional requests and content-type detection.  It
+// returns false, without writing anything, if the file doesn't exist or is a
+// directory.
+func serveFile(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name string) bool {
+	f, err := fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
+	}
+
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	return true
+}
+
+// StaticFallback returns a Handler, intended to be used as a router's NotFound
+// handler, that serves requests that don't match any route from the given
+// file system.  If the request's path names a file, that file is served;
+// otherwise, the given index file (relative to the root of fs) is served, as
+// needed by single-page applications that route on the client.  If neither
+// exists, or the request isn't a GET or HEAD request, a 404 Not Found response
+// is sent.
+//
+// An empty indexFile disables the fallback, so that only existing files are
+// served.
+func StaticFallback(fs http.FileSystem, indexFile string) Handler {
+	return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			if serveFile(w, r, fs, path.Clean("/"+r.URL.Path)) {
+				return
+			}
+			if indexFile != "" && serveFile(w, r, fs, path.Clean("/"+indexFile)) {
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	})
+}
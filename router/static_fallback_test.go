@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// makeStaticDir creates a temporary directory with some static files.
+func makeStaticDir(t *testing.T) http.FileSystem {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":    "<html>app</html>",
+		"css/site.css":  "body {}",
+		"media/big.txt": "0123456789abcdefghij",
+	}
+	for name, contents := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return http.Dir(dir)
+}
+
+func TestStaticFallback(t *testing.T) {
+	t.Parallel()
+
+	fs := makeStaticDir(t)
+	send := func(h Handler, method, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+
+	h := StaticFallback(fs, "index.html")
+
+	// Existing files are served...
+	w := send(h, "GET", "/css/site.css")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body {}", w.Body.String())
+	assert.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+
+	// ... and anything else gets the index file, including directories.
+	for _, path := range []string{"/app/settings", "/css", "/../index.html"} {
+		w = send(h, "GET", path)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "<html>app</html>", w.Body.String(), path)
+	}
+
+	// Only GET and HEAD requests are served.
+	w = send(h, "POST", "/css/site.css")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Without an index file, missing files are a 404.
+	h = StaticFallback(fs, "")
+	w = send(h, "GET", "/app/settings")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(h, "GET", "/css/site.css")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	h = StaticFallback(fs, "missing.html")
+	w = send(h, "GET", "/app/settings")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -16,10 +17,75 @@ type StringPattern struct {
 	breaks   []byte   // Break characters
 	literals []string // Literal component before a pattern
 	wildcard bool     // Has a wildcard match at the end?
+	wildname string   // Name the wildcard's tail is bound to (default "*")
+	single   bool     // Exactly one parameter and no wildcard?
+
+	opts StringPatternOptions // Options given when parsing
+}
+
+// StringPatternOptions controls how a string pattern is parsed and matched.
+// The zero value gives the behaviour of ParseStringPattern.
+type StringPatternOptions struct {
+	// IgnoreTrailingSlash causes a trailing slash on both the pattern and
+	// the request path to be ignored, so that "/foo" and "/foo/" match the
+	// same paths.  It has no effect on patterns with a trailing wildcard.
+	IgnoreTrailingSlash bool
+
+	// IgnoreCase causes the literal portions of the pattern to be matched
+	// case-insensitively.  The values of parameters are not changed.
+	IgnoreCase bool
+
+	// RawParams causes the pattern to be matched against the request's
+	// escaped path (see url.URL.EscapedPath), so that parameters are bound
+	// without being unescaped - e.g. "/:name" binds "a%2Fb" from the path
+	// "/a%2Fb", instead of failing to match "/a/b".
+	RawParams bool
+
+	// Breaks is the set of break characters that can end a parameter (see
+	// the documentation for PatternType).  If empty, the default set of
+	// "/.;," is used.  A slash is always a break character.
+	Breaks string
+
+	// MatrixParams causes RFC 3986 matrix parameters in a segment matched by
+	// a parameter to be bound separately.  For example, "/cars/:id" matches
+	// the path "/cars/42;color=red;doors=4", binding the URL parameter "id"
+	// to "42", and the matrix parameters "color" and "doors" to "red" and "4"
+	// (see GetMatrixParams).  Matrix parameters without a value (e.g. ";new")
+	// are bound to the empty string.
+	MatrixParams bool
 }
 
 func (s StringPattern) Prefix() string {
-	return s.literals[0]
+	// A case-insensitive literal can't be used as a (case-sensitive) prefix,
+	// nor can a literal containing escapes be compared with the unescaped
+	// path that the prefix is checked against.
+	if s.opts.IgnoreCase || (s.opts.RawParams && strings.Contains(s.literals[0], "%")) {
+		return ""
+	}
+	return usefulPrefix(s.literals[0])
+}
+
+// hasPrefix, hasSuffix and equal compare a portion of the path with a
+// literal, respecting the IgnoreCase option.
+func (s StringPattern) hasPrefix(path, lit string) bool {
+	if s.opts.IgnoreCase {
+		return len(path) >= len(lit) && strings.EqualFold(path[:len(lit)], lit)
+	}
+	return strings.HasPrefix(path, lit)
+}
+
+func (s StringPattern) hasSuffix(path, lit string) bool {
+	if s.opts.IgnoreCase {
+		return len(path) >= len(lit) && strings.EqualFold(path[len(path)-len(lit):], lit)
+	}
+	return strings.HasSuffix(path, lit)
+}
+
+func (s StringPattern) equal(path, lit string) bool {
+	if s.opts.IgnoreCase {
+		return strings.EqualFold(path, lit)
+	}
+	return path == lit
 }
 
 func (s StringPattern) Match(r *http.Request) bool {
@@ -30,14 +96,72 @@ func (s StringPattern) Run(r *http.Request, c *context.Context) {
 	s.match(r, c, false)
 }
 
+// MatchPath implements PathMatcher.  If the pattern was parsed with the
+// RawParams option, the path should be escaped (as by url.URL.EscapedPath).
+func (s StringPattern) MatchPath(path string) bool {
+	return s.matchPath(path, nil, true)
+}
+
+// HasWildcard returns whether the pattern ends with a wildcard.
+func (s StringPattern) HasWildcard() bool {
+	return s.wildcard
+}
+
+// IsStatic returns whether the pattern is a plain literal path, with neither
+// parameters nor a wildcard.
+func (s StringPattern) IsStatic() bool {
+	return len(s.pats) == 0 && !s.wildcard
+}
+
+// ParamNames returns the names of the parameters bound by the pattern, in the
+// order in which they appear, followed by the name of the wildcard (if any).
+func (s StringPattern) ParamNames() []string {
+	names := make([]string, 0, len(s.pats)+1)
+	names = append(names, s.pats...)
+	if s.wildcard {
+		names = append(names, s.wildname)
+	}
+	return names
+}
+
+// Template returns the pattern as a URI template, with each parameter and the
+// wildcard (if any) replaced by its name in braces.  For example, the pattern
+// "/users/:id/files/*path" has the template "/users/{id}/files/{path}".
+func (s StringPattern) Template() string {
+	var buf bytes.Buffer
+	for i, name := range s.pats {
+		buf.WriteString(s.literals[i])
+		buf.WriteString("{" + name + "}")
+	}
+	buf.WriteString(s.literals[len(s.pats)])
+	if s.wildcard {
+		buf.WriteString("{" + s.wildname + "}")
+	}
+	return buf.String()
+}
+
 func (s StringPattern) match(r *http.Request, c *context.Context, dryrun bool) bool {
 	path := r.URL.Path
+	if s.opts.RawParams {
+		path = r.URL.EscapedPath()
+	}
+	return s.matchPath(path, c, dryrun)
+}
+
+func (s StringPattern) matchPath(path string, c *context.Context, dryrun bool) bool {
+	if s.opts.IgnoreTrailingSlash && !s.wildcard && len(path) > 1 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
 
-	var matches map[string]string
+	var (
+		matches, matrix map[string]string
+		singleVal       string
+	)
 
 	// Only allocate when we're actually running the pattern - i.e. not when
-	// we're just testing for a match.
-	if !dryrun {
+	// we're just testing for a match.  Patterns with a single parameter
+	// don't need a map at all (see setSingleURLParam).
+	if !dryrun && !s.single {
 		if s.wildcard {
 			matches = make(map[string]string, len(s.pats)+1)
 		} else if len(s.pats) != 0 {
@@ -49,27 +173,63 @@ func (s StringPattern) match(r *http.Request, c *context.Context, dryrun bool) b
 		// Get the literal that precedes this pattern, and verify that the path
 		// starts with the literal.
 		sli := s.literals[i]
-		if !strings.HasPrefix(path, sli) {
+		if !s.hasPrefix(path, sli) {
 			return false
 		}
 		path = path[len(sli):]
 
 		m := 0
-		bc := s.breaks[i]
-		for ; m < len(path); m++ {
-			if path[m] == bc || path[m] == '/' {
-				break
+		if i == len(s.pats)-1 && !s.wildcard {
+			// The last parameter extends up to the final literal, so that
+			// a literal suffix after a break character is matched as a
+			// whole (e.g. "/:name.json" matches "/my.file.json").
+			tail := s.literals[len(s.pats)]
+			if !s.hasSuffix(path, tail) {
+				return false
+			}
+
+			m = len(path) - len(tail)
+			if strings.IndexByte(path[:m], '/') >= 0 {
+				return false
+			}
+		} else {
+			bc := s.breaks[i]
+			for ; m < len(path); m++ {
+				if path[m] == bc || path[m] == '/' {
+					break
+				}
 			}
 		}
 
 		if m == 0 {
 			// Empty strings are not matches, otherwise routes like
-			// "/:foo" would match the path "/"
+			// "/:foo" would match the path "/".  This applies to every
+			// parameter, whether it's ended by a break character or by the
+			// final literal (e.g. "/a/:x/" doesn't match "/a//").
 			return false
 		}
 
-		if !dryrun {
-			matches[pat] = path[:m]
+		val := path[:m]
+		if s.opts.MatrixParams {
+			if j := strings.IndexByte(val, ';'); j >= 0 {
+				if j == 0 {
+					// As above, empty values are not matches.
+					return false
+				}
+				if !dryrun {
+					if matrix == nil {
+						matrix = make(map[string]string)
+					}
+					parseMatrixParams(val[j+1:], matrix)
+				}
+				val = val[:j]
+			}
+		}
+
+		if s.single {
+			singleVal = val
+		} else if !dryrun {
+			matches[pat] = val
 		}
 
 		// Skip past this chunk
@@ -81,14 +241,14 @@ func (s StringPattern) match(r *http.Request, c *context.Context, dryrun bool) b
 	if s.wildcard {
 		// This last literal is everything before the wildcard, so the path
 		// must start with it.
-		if !strings.HasPrefix(path, tail) {
+		if !s.hasPrefix(path, tail) {
 			return false
 		}
 
 		if !dryrun {
-			matches["*"] = path[len(tail)-1:]
+			matches[s.wildname] = path[len(tail)-1:]
 		}
-	} else if path != tail {
+	} else if !s.equal(path, tail) {
 		return false
 	}
 
@@ -98,14 +258,96 @@ func (s StringPattern) match(r *http.Request, c *context.Context, dryrun bool) b
 	}
 
 	// Set URL parameters in the context
-	*c = SetURLParams(*c, matches)
+	if s.single {
+		*c = setSingleURLParam(*c, s.pats[0], singleVal)
+	} else {
+		*c = MergeURLParams(*c, matches)
+	}
+	if matrix != nil {
+		*c = SetMatrixParams(*c, matrix)
+	}
 	return true
 }
 
+// parseMatrixParams parses a set of semicolon-separated matrix parameters
+// (e.g. "color=red;doors=4") into the given map.
+func parseMatrixParams(s string, into map[string]string) {
+	for _, param := range strings.Split(s, ";") {
+		if param == "" {
+			continue
+		}
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			into[param[:i]] = param[i+1:]
+		} else {
+			into[param] = ""
+		}
+	}
+}
+
+// Source implements SourcePattern, returning the pattern as it was given to
+// ParseStringPattern.
+func (s StringPattern) Source() string {
+	return s.raw
+}
+
 func (s StringPattern) String() string {
 	return fmt.Sprintf("StringPattern(%q)", s.raw)
 }
 
+// Canonical returns a normalized form of this pattern, suitable for comparing
+// two patterns for equality (e.g. as a map key).  The names of parameters and
+// wildcards are dropped, since they do not affect which paths a pattern
+// matches.  For example, the patterns "/users/:id" and "/users/:name" both have
+// the canonical form "/users/:", and "/files/*path" has the canonical form
+// "/files/*".  Repeated slashes are kept, since paths aren't cleaned before
+// being matched, and so "//users/:id" matches different paths.  Literal
+// occurrences of ':', '*', '[' and '\' are escaped with a backslash, so that
+// e.g. the literal pattern "/a/:" (which has the canonical form "/a/\:") can't
+// be confused with the parameter in "/a/:x".
+//
+// The options that the pattern was parsed with (see StringPatternOptions) are
+// appended in brackets if they change which paths it matches - e.g. the
+// pattern "/Users/:id" parsed with IgnoreCase has the canonical form
+// "/users/: [IgnoreCase]".
+func (s StringPattern) Canonical() string {
+	var buf bytes.Buffer
+	for i := range s.pats {
+		buf.WriteString(canonicalEscaper.Replace(s.literals[i]))
+		buf.WriteByte(':')
+	}
+	buf.WriteString(canonicalEscaper.Replace(s.literals[len(s.pats)]))
+	if s.wildcard {
+		buf.WriteByte('*')
+	}
+
+	var opts []string
+	if s.opts.IgnoreTrailingSlash && !s.wildcard {
+		opts = append(opts, "IgnoreTrailingSlash")
+	}
+	if s.opts.IgnoreCase {
+		opts = append(opts, "IgnoreCase")
+	}
+	if s.opts.RawParams {
+		opts = append(opts, "RawParams")
+	}
+	if s.opts.MatrixParams {
+		opts = append(opts, "MatrixParams")
+	}
+
+	canon := buf.String()
+	if s.opts.IgnoreCase {
+		canon = strings.ToLower(canon)
+	}
+	if len(opts) > 0 {
+		canon += " [" + strings.Join(opts, ",") + "]"
+	}
+	return canon
+}
+
+// canonicalEscaper escapes the characters in a literal that have a special
+// meaning in a canonical form.
+var canonicalEscaper = strings.NewReplacer(`\`, `\\`, `:`, `\:`, `*`, `\*`, `[`, `\[`)
+
 // "Break characters" are characters that can end patterns. They are not allowed
 // to appear in pattern names. "/" was chosen because it is the standard path
 // separator, and "." was chosen because it often delimits file extensions. ";"
@@ -114,19 +356,60 @@ const bc = "/.;,"
 
 var patternRe = regexp.MustCompile(`[` + bc + `]:([^` + bc + `]+)`)
 
+// breakPatternRe returns the regexp used to find parameters in a pattern with
+// the given set of break characters.
+func breakPatternRe(breaks string) *regexp.Regexp {
+	if breaks == "" || breaks == bc {
+		return patternRe
+	}
+	if !strings.Contains(breaks, "/") {
+		breaks += "/"
+	}
+
+	// Escape every character, so that none of them are interpreted specially
+	// in the character class (e.g. "-" or "]").
+	var class bytes.Buffer
+	for i := 0; i < len(breaks); i++ {
+		fmt.Fprintf(&class, `\x%02x`, breaks[i])
+	}
+	return regexp.MustCompile(`[` + class.String() + `]:([^` + class.String() + `]+)`)
+}
+
 // ParseStringPattern takes a Sinatra-style string pattern and decomposes it
 // into its constituent components.
 func ParseStringPattern(s string) StringPattern {
+	return ParseStringPatternOpts(s, StringPatternOptions{})
+}
+
+// ParseStringPatternOpts is like ParseStringPattern, but allows controlling
+// how the pattern is parsed and matched with the given options.
+func ParseStringPatternOpts(s string, opts StringPatternOptions) StringPattern {
 	raw := s
 
-	// Check for wildcard matches, then trim the suffix if it's there.
-	var wildcard bool
-	if strings.HasSuffix(s, "/*") {
-		s = s[:len(s)-1]
+	// Check for wildcard matches, then trim the suffix if it's there.  The
+	// wildcard may optionally be followed by a name (e.g. "/files/*path"),
+	// which is used to bind the tail instead of the special key "*".  As with
+	// parameters, the name can't contain break characters, so that a final
+	// segment such as "*.txt" is a literal rather than a wildcard.
+	var (
+		wildcard bool
+		wildname string
+	)
+	breakChars := opts.Breaks
+	if breakChars == "" {
+		breakChars = bc
+	}
+	if i := strings.LastIndex(s, "/*"); i >= 0 && !strings.ContainsAny(s[i+2:], breakChars+"/") {
+		wildname = s[i+2:]
+		if wildname == "" {
+			wildname = "*"
+		}
+
+		s = s[:i+1]
 		wildcard = true
 	}
 
-	matches := patternRe.FindAllStringSubmatchIndex(s, -1)
+	matches := breakPatternRe(opts.Breaks).FindAllStringSubmatchIndex(s, -1)
 
 	pats := make([]string, len(matches))
 	breaks := make([]byte, len(matches))
@@ -152,11 +435,23 @@ func ParseStringPattern(s string) StringPattern {
 	// Any remaining string is the last literal.
 	literals[len(matches)] = s[n:]
 
+	// If we're ignoring trailing slashes, we remove it from the pattern here,
+	// and from the path when matching.
+	if opts.IgnoreTrailingSlash && !wildcard {
+		last := literals[len(matches)]
+		if strings.HasSuffix(last, "/") && (len(matches) > 0 || len(last) > 1) {
+			literals[len(matches)] = last[:len(last)-1]
+		}
+	}
+
 	return StringPattern{
 		raw:      raw,
 		pats:     pats,
 		breaks:   breaks,
 		literals: literals,
 		wildcard: wildcard,
+		wildname: wildname,
+		single:   len(pats) == 1 && !wildcard,
+		opts:     opts,
 	}
 }
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+)
+
+// StripPrefix returns a Handler that serves requests by running h with the
+// request's path replaced by the wildcard parameter ("*") bound by the route
+// that matched.  This allows a router to be mounted underneath a wildcard
+// route - e.g. a router registered at "/api/:version/*" will see a request for
+// "/api/v1/users/123" as being for "/users/123".
+//
+// All other parameters bound by the matched route remain in the context, and
+// are merged with those bound by h's own routes, so that a handler deep within
+// nested routers can retrieve the parameters from every level.  If the route
+// didn't bind a wildcard, the request is passed to h unchanged.
+func StripPrefix(h Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		tail, ok := GetURLParam(ctx, "*")
+		if !ok {
+			h.ServeHTTPC(ctx, w, r)
+			return
+		}
+		params := GetURLParams(ctx)
+
+		// Don't pass the wildcard on to the nested router, since it only
+		// makes sense at this level.
+		outer := make(map[string]string, len(params)-1)
+		for k, v := range params {
+			if k != "*" {
+				outer[k] = v
+			}
+		}
+		ctx = SetURLParams(ctx, outer)
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = tail
+		r2.URL.RawPath = ""
+		if r2.URL.Path == "" {
+			r2.URL.Path = "/"
+		}
+
+		h.ServeHTTPC(ctx, w, r2)
+	})
+}
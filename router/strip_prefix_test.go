@@ -0,0 +1,54 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMergeURLParams(t *testing.T) {
+	t.Parallel()
+
+	ctx := MergeURLParams(context.Background(), map[string]string{"a": "1"})
+	assert.Equal(t, map[string]string{"a": "1"}, GetURLParams(ctx))
+
+	merged := MergeURLParams(ctx, map[string]string{"a": "2", "b": "3"})
+	assert.Equal(t, map[string]string{"a": "2", "b": "3"}, GetURLParams(merged))
+
+	// The original context is unchanged.
+	assert.Equal(t, map[string]string{"a": "1"}, GetURLParams(ctx))
+}
+
+func TestStripPrefix(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path   string
+		params map[string]string
+	)
+	inner := ParsePattern("/users/:id")
+	h := StripPrefix(HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if inner.Match(r) {
+			inner.Run(r, &ctx)
+		}
+		params = GetURLParams(ctx)
+	}))
+
+	r, _ := http.NewRequest("GET", "/api/v1/users/123", nil)
+	ctx := context.Background()
+	ParsePattern("/api/:version/*").Run(r, &ctx)
+	h.ServeHTTPC(ctx, httptest.NewRecorder(), r)
+
+	assert.Equal(t, "/users/123", path)
+	assert.Equal(t, map[string]string{
+		"version": "v1",
+		"id":      "123",
+	}, params)
+
+	// The original request isn't modified.
+	assert.Equal(t, "/api/v1/users/123", r.URL.Path)
+}
@@ -9,6 +9,10 @@ package types
 //	- types that implement Handler
 //	- func(http.ResponseWriter, *http.Request)
 //	- func(context.Context, http.ResponseWriter, *http.Request)
+//	- types that implement ErrorHandler
+//	- func(context.Context, http.ResponseWriter, *http.Request) error
+//	- func(context.Context, *http.Request) (interface{}, error), whose result
+//	  is encoded according to the request's Accept header
 type HandlerType interface{}
 
 // MiddlewareType is an alias for interface{}, but is documented here for
@@ -39,6 +43,10 @@ type MiddlewareType interface{}
 //   	  unmatched tail of the match, but including the leading "/". So
 //   	  for the two matching examples above, "*" would be bound to "/"
 //   	  and "/projects/123" respectively.
+//   	- the wildcard may optionally be given a name, in which case the
+//   	  tail is bound to that name instead of "*". For instance, the
+//   	  pattern "/files/*path" will match "/files/a/b.txt", binding
+//   	  "path" to "/a/b.txt".
 //     Unlike http.ServeMux's patterns, string patterns support neither the
 //     "rooted subtree" behavior nor Host-specific routes. Users who require
 //     either of these features are encouraged to compose package http's mux